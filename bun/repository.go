@@ -0,0 +1,314 @@
+package bun
+
+import (
+	"context"
+	"database/sql"
+	"go_sql_library/dberr"
+	"go_sql_library/model"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// User bunモデル（model.Userとは別に定義）
+type User struct {
+	bun.BaseModel `bun:"table:users"`
+
+	ID        int       `bun:"id,pk,autoincrement"`
+	Name      string    `bun:"name,notnull"`
+	Email     string    `bun:"email,notnull,unique"`
+	Version   int       `bun:"version,notnull,default:1"`
+	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp"`
+	DeletedAt time.Time `bun:"deleted_at,soft_delete,nullzero"`
+}
+
+// createManyBatchSize CreateManyで1つのINSERT文にまとめる最大件数
+const createManyBatchSize = 1000
+
+// UserRepository bunを使ったユーザーリポジトリ
+type UserRepository struct {
+	db *bun.DB
+
+	// q クエリの実行先（WithTx内ではbun.Tx）
+	q bun.IDB
+
+	// inTx WithTx内のリポジトリかどうか
+	inTx bool
+}
+
+// NewUserRepository リポジトリの初期化
+// bunは日時をSQLへ埋め込むため、dbはドライバ（loc=UTC）と同じくmysqldialect.WithTimeLocation("UTC")を指定して作ること
+func NewUserRepository(db *bun.DB) *UserRepository {
+	return &UserRepository{db: db, q: db}
+}
+
+// toModelUser bun UserをmodelのUserに変換
+func toModelUser(u *User) *model.User {
+	user := &model.User{
+		ID:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Version:   u.Version,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+	}
+	if !u.DeletedAt.IsZero() {
+		deletedAt := u.DeletedAt
+		user.DeletedAt = &deletedAt
+	}
+	return user
+}
+
+// toModelUsers bun Userのスライスをmodel.Userのスライスに変換
+func toModelUsers(bunUsers []User) []model.User {
+	users := make([]model.User, len(bunUsers))
+	for i, u := range bunUsers {
+		users[i] = *toModelUser(&u)
+	}
+	return users
+}
+
+// GetAll 削除されていない全ユーザーを取得
+// soft_deleteを指定したモデルは論理削除済みの行が自動的に除外される
+func (r *UserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	var bunUsers []User
+	if err := r.q.NewSelect().Model(&bunUsers).Order("id").Scan(ctx); err != nil {
+		return nil, err
+	}
+	return toModelUsers(bunUsers), nil
+}
+
+// List filterに一致するユーザーをIDの昇順でpage.AfterIDより後ろから最大page.Limit件取得
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter, page model.Page) ([]model.User, error) {
+	var bunUsers []User
+	q := r.q.NewSelect().Model(&bunUsers).Where("id > ?", page.AfterID)
+	if filter.IncludeDeleted {
+		q = q.WhereAllWithDeleted()
+	}
+	if filter.NameContains != "" {
+		q = q.Where("name LIKE ? ESCAPE '"+model.LikeEscapeChar+"'", "%"+model.EscapeLike(filter.NameContains)+"%")
+	}
+	if filter.EmailDomain != "" {
+		q = q.Where("email LIKE ? ESCAPE '"+model.LikeEscapeChar+"'", "%@"+model.EscapeLike(filter.EmailDomain))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		q = q.Where("created_at >= ?", filter.CreatedFrom)
+	}
+	if !filter.CreatedTo.IsZero() {
+		q = q.Where("created_at < ?", filter.CreatedTo)
+	}
+
+	if err := q.Order("id").Limit(page.Limit).Scan(ctx); err != nil {
+		return nil, err
+	}
+	return toModelUsers(bunUsers), nil
+}
+
+// GetByID IDで削除されていないユーザーを取得
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	var u User
+	if err := r.q.NewSelect().Model(&u).Where("id = ?", id).Scan(ctx); err != nil {
+		return nil, dberr.Translate(err)
+	}
+	return toModelUser(&u), nil
+}
+
+// getByEmail メールアドレスで削除されていないユーザーを取得
+func (r *UserRepository) getByEmail(ctx context.Context, email string) (*model.User, error) {
+	var u User
+	if err := r.q.NewSelect().Model(&u).Where("email = ?", email).Scan(ctx); err != nil {
+		return nil, dberr.Translate(err)
+	}
+	return toModelUser(&u), nil
+}
+
+// Create 新規ユーザーを作成
+// MySQLはRETURNINGに対応していないため、採番されたIDで取得し直して日時を埋める
+func (r *UserRepository) Create(ctx context.Context, name, email string) (*model.User, error) {
+	u := User{
+		Name:  name,
+		Email: email,
+	}
+	if _, err := r.q.NewInsert().Model(&u).Exec(ctx); err != nil {
+		return nil, dberr.Translate(err)
+	}
+	return r.GetByID(ctx, u.ID)
+}
+
+// CreateMany 複数のユーザーをスライスのInsertでまとめて作成
+// createManyBatchSize件ごとに1つの複数行INSERT文を発行し、全体を1つのトランザクションで実行する
+// 各行のIDはbunが先頭のIDから連番で設定する
+func (r *UserRepository) CreateMany(ctx context.Context, users []model.NewUser) ([]int, error) {
+	ids := make([]int, 0, len(users))
+	err := r.WithTx(ctx, func(repo model.UserRepository) error {
+		tx := repo.(*UserRepository)
+		for start := 0; start < len(users); start += createManyBatchSize {
+			end := min(start+createManyBatchSize, len(users))
+
+			bunUsers := make([]User, 0, end-start)
+			for _, u := range users[start:end] {
+				bunUsers = append(bunUsers, User{Name: u.Name, Email: u.Email})
+			}
+			if _, err := tx.q.NewInsert().Model(&bunUsers).Exec(ctx); err != nil {
+				return dberr.Translate(err)
+			}
+			for _, u := range bunUsers {
+				ids = append(ids, u.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Upsert メールアドレスをキーにユーザーを作成または名前を更新
+// ON DUPLICATE KEY UPDATEの影響行数で挿入か更新かを判定する。論理削除済みのユーザーは復元する
+func (r *UserRepository) Upsert(ctx context.Context, name, email string) (*model.User, bool, error) {
+	u := User{
+		Name:  name,
+		Email: email,
+	}
+	result, err := r.q.NewInsert().Model(&u).
+		On("DUPLICATE KEY UPDATE").
+		Set("name = VALUES(name)").
+		Set("deleted_at = NULL").
+		Set("version = version + 1").
+		Exec(ctx)
+	if err != nil {
+		return nil, false, dberr.Translate(err)
+	}
+
+	// 挿入時は1、既存行の更新時は2になる
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	user, err := r.getByEmail(ctx, email)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, affected == 1, nil
+}
+
+// Update 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+// soft_deleteを指定したモデルのUPDATEには論理削除済みの行を除く条件が自動的に付く
+func (r *UserRepository) Update(ctx context.Context, id int, name, email string, version int) error {
+	result, err := r.q.NewUpdate().Model((*User)(nil)).
+		Set("name = ?", name).
+		Set("email = ?", email).
+		Set("version = version + 1").
+		Where("id = ? AND version = ?", id, version).
+		Exec(ctx)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// Patch 指定したフィールドだけをバージョンが一致する場合のみ更新
+func (r *UserRepository) Patch(ctx context.Context, id int, patch model.UserPatch, version int) error {
+	q := r.q.NewUpdate().Model((*User)(nil)).Set("version = version + 1")
+	if patch.Name != nil {
+		q = q.Set("name = ?", *patch.Name)
+	}
+	if patch.Email != nil {
+		q = q.Set("email = ?", *patch.Email)
+	}
+
+	result, err := q.Where("id = ? AND version = ?", id, version).Exec(ctx)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// Delete ユーザーをバージョンが一致する場合のみ論理削除
+// soft_deleteを指定したモデルのDeleteはdeleted_atを設定するUPDATEになる
+func (r *UserRepository) Delete(ctx context.Context, id int, version int) error {
+	result, err := r.q.NewDelete().Model((*User)(nil)).
+		Where("id = ? AND version = ?", id, version).
+		Exec(ctx)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// checkVersionMatched バージョン付きの更新で対象行がなかった場合にその原因をエラーとして返す
+// ユーザーが存在しなければErrNotFound、存在すればバージョン不一致としてErrVersionConflictを返す
+func (r *UserRepository) checkVersionMatched(ctx context.Context, result sql.Result, id int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return model.ErrVersionConflict
+}
+
+// Restore 論理削除したユーザーを復元
+func (r *UserRepository) Restore(ctx context.Context, id int) error {
+	result, err := r.q.NewUpdate().Model((*User)(nil)).
+		WhereDeleted().
+		Set("deleted_at = NULL").
+		Set("version = version + 1").
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// Purge olderThanより前に論理削除したユーザーを物理削除し、削除件数を返す
+// モデルを指定したDELETEはテーブルに別名を付ける（DELETE FROM users AS user）が、
+// 別名付きのDELETEに対応しないMySQL互換サーバーがあるため、テーブル名だけを指定する
+func (r *UserRepository) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	result, err := r.q.NewDelete().TableExpr("users").
+		Where("deleted_at < ?", olderThan).
+		Exec(ctx)
+	if err != nil {
+		return 0, dberr.Translate(err)
+	}
+	return result.RowsAffected()
+}
+
+// WithTx fnをトランザクション内で実行する
+// コミットとロールバック（パニック時を含む）はdb.RunInTxに任せる
+func (r *UserRepository) WithTx(ctx context.Context, fn func(repo model.UserRepository) error) error {
+	// 既にトランザクション内であれば同じトランザクションに参加する
+	if r.inTx {
+		return fn(r)
+	}
+
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return fn(&UserRepository{db: r.db, q: tx, inTx: true})
+	})
+}
+
+// Close データベース接続を閉じる
+// トランザクション内のリポジトリは接続を所有しないため何もしない
+func (r *UserRepository) Close() error {
+	if r.inTx {
+		return nil
+	}
+	return r.db.Close()
+}
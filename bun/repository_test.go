@@ -0,0 +1,23 @@
+package bun
+
+import (
+	"go_sql_library/dbtest"
+	"go_sql_library/model"
+	"go_sql_library/repositorytest"
+	"testing"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+)
+
+// setupTestDB MySQLにテスト専用のデータベースを作って接続する（テスト終了時に削除される）
+func setupTestDB(t *testing.T) *bun.DB {
+	return bun.NewDB(dbtest.OpenMySQL(t), mysqldialect.New(mysqldialect.WithTimeLocation("UTC")))
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+	repositorytest.RunConformance(t, func(t *testing.T) model.UserRepository {
+		return NewUserRepository(setupTestDB(t))
+	})
+}
@@ -0,0 +1,68 @@
+// Package dbdriver 接続先のデータベース（MySQL、SQLiteまたはPostgreSQL）の選択と、SQLiteの接続
+package dbdriver
+
+import (
+	"database/sql"
+	"net"
+	"net/url"
+	"os"
+
+	"github.com/glebarez/go-sqlite"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+)
+
+// 接続先のデータベース（database/sqlのドライバ名）
+const (
+	// MySQL go-sql-driver/mysql
+	MySQL = "mysql"
+
+	// SQLite cgoを使わないglebarez/go-sqlite
+	SQLite = "sqlite"
+
+	// Postgres jackc/pgxのdatabase/sql用ドライバ
+	Postgres = "postgres"
+)
+
+// pgxはドライバ名"pgx"で登録されるため、DB_DRIVERと同じ名前でも開けるようにする
+func init() {
+	sql.Register(Postgres, stdlib.GetDefaultDriver())
+}
+
+// FromEnv 環境変数DB_DRIVERから接続先を取得（未指定の場合はMySQL）
+func FromEnv() string {
+	if driver := os.Getenv("DB_DRIVER"); driver != "" {
+		return driver
+	}
+	return MySQL
+}
+
+// Name dbが接続しているデータベースのドライバ名を返す
+func Name(db *sql.DB) string {
+	switch db.Driver().(type) {
+	case *sqlite.Driver:
+		return SQLite
+	case *stdlib.Driver:
+		return Postgres
+	}
+	return MySQL
+}
+
+// Rebind ?で書いたクエリのプレースホルダをdriverの形式に変換する
+// PostgreSQLでは$1, $2, ...に置き換え、MySQLとSQLiteではそのまま返す
+func Rebind(driver, query string) string {
+	return sqlx.Rebind(sqlx.BindType(driver), query)
+}
+
+// PostgresDSN PostgreSQLへの接続文字列を組み立てる
+// 開発環境のコンテナはTLSを使わないためsslmode=disableにする
+func PostgresDSN(host, port, user, password, dbName string) string {
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(user, password),
+		Host:     net.JoinHostPort(host, port),
+		Path:     "/" + dbName,
+		RawQuery: "sslmode=disable",
+	}
+	return u.String()
+}
@@ -0,0 +1,102 @@
+package dbdriver
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+
+	"github.com/glebarez/go-sqlite"
+)
+
+// SQLiteTimeFormat SQLiteに日時を書き込むときの形式
+// SQLiteは日時を文字列として比較するため、CURRENT_TIMESTAMPと同じUTCの「YYYY-MM-DD HH:MM:SS」に揃える
+// （小数部は0でなければ続けて書き込むため、同じ秒の値との比較でも順序が保たれる）
+const SQLiteTimeFormat = "2006-01-02 15:04:05.999999999"
+
+// OpenSQLite pathのSQLiteデータベースを開く（テーブルはマイグレーションで作成する）
+// SQLiteは同時に1つの書き込みしかできないため、接続を1つにしてロック待ちのエラーを避ける
+// 引数の日時はドライバの既定の形式（タイムゾーン付き）ではなく、SQLiteTimeFormatで書き込む
+func OpenSQLite(path string) (*sql.DB, error) {
+	db := sql.OpenDB(sqliteConnector{dsn: path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"})
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// sqliteConnector glebarez/go-sqliteの接続をsqliteConnで包んで返すコネクタ
+type sqliteConnector struct {
+	dsn string
+}
+
+func (c sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn: conn.(sqliteDriverConn)}, nil
+}
+
+// Driver Nameで接続先を判定できるよう、glebarez/go-sqliteのドライバを返す
+func (c sqliteConnector) Driver() driver.Driver {
+	return &sqlite.Driver{}
+}
+
+// sqliteDriverConn glebarez/go-sqliteの接続が実装しているインターフェース
+type sqliteDriverConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+}
+
+// sqliteConn 引数の日時をSQLiteTimeFormatの文字列に変換してから渡す接続
+type sqliteConn struct {
+	conn sqliteDriverConn
+}
+
+// CheckNamedValue 引数をdatabase/sqlの既定の規則で変換し、日時はSQLiteTimeFormatの文字列にする
+func (c *sqliteConn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+	if t, ok := v.(time.Time); ok {
+		v = t.UTC().Format(SQLiteTimeFormat)
+	}
+	nv.Value = v
+	return nil
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(query)
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.conn.PrepareContext(ctx, query)
+}
+
+func (c *sqliteConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *sqliteConn) Begin() (driver.Tx, error) {
+	return c.conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *sqliteConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.conn.BeginTx(ctx, opts)
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.conn.ExecContext(ctx, query, args)
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.conn.QueryContext(ctx, query, args)
+}
+
+func (c *sqliteConn) Ping(ctx context.Context) error {
+	return c.conn.Ping(ctx)
+}
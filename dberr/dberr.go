@@ -0,0 +1,65 @@
+// Package dberr ドライバ固有のエラーをmodelのドメインエラーに変換する
+package dberr
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go_sql_library/model"
+	"strings"
+
+	"github.com/glebarez/go-sqlite"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// mysqlErrDupEntry 一意制約違反（Duplicate entry）
+const mysqlErrDupEntry = 1062
+
+// pgUniqueViolation PostgreSQLの一意制約違反（unique_violation）
+const pgUniqueViolation = "23505"
+
+// pgPrimaryKey PostgreSQLが付けるusersテーブルの主キー制約の名前
+const pgPrimaryKey = "users_pkey"
+
+// Translate エラーをドメインエラーに変換する
+// 変換できないエラーはそのまま返す
+func Translate(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.ErrNotFound
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) && myErr.Number == mysqlErrDupEntry {
+		// usersテーブルの主キー以外の一意制約はemailのみ
+		if strings.Contains(strings.ToLower(myErr.Message), "primary") {
+			return fmt.Errorf("%w: %v", model.ErrConflict, err)
+		}
+		return fmt.Errorf("%w: %v", model.ErrDuplicateEmail, err)
+	}
+
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) {
+		switch liteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return fmt.Errorf("%w: %v", model.ErrConflict, err)
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE:
+			return fmt.Errorf("%w: %v", model.ErrDuplicateEmail, err)
+		}
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+		if pgErr.ConstraintName == pgPrimaryKey {
+			return fmt.Errorf("%w: %v", model.ErrConflict, err)
+		}
+		return fmt.Errorf("%w: %v", model.ErrDuplicateEmail, err)
+	}
+
+	return err
+}
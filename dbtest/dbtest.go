@@ -0,0 +1,187 @@
+// Package dbtest テストケースごとに専用のデータベースを用意する
+//
+// テストケースごとに一意な名前のデータベース（PostgreSQLではスキーマ）を作り、
+// マイグレーション（migrateパッケージ）でテーブルを作成して、テスト終了時に削除する。
+// テスト同士でデータが干渉しないため、後片付けのDELETEが不要になり、t.Parallelで並行に実行できる。
+// SQLiteの場合は一時ディレクトリにデータベースファイルを作る。
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go_sql_library/dbdriver"
+	"go_sql_library/migrate"
+	"go_sql_library/mysqltest"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jmoiron/sqlx"
+	gormmysql "gorm.io/driver/mysql"
+	gormpostgres "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// namePrefix テスト用に作るデータベースの名前の接頭辞
+// テストが異常終了して削除されずに残った場合は、この名前で探して削除する
+const namePrefix = "test_"
+
+// adminDB テスト用のデータベースを作成・削除するときの接続先（compose.ymlで作成するデータベース）
+const adminDB = "testdb"
+
+// Open DB_DRIVERで選んだ接続先にテスト専用のデータベースを作り、接続した*sql.DBを返す
+func Open(tb testing.TB) *sql.DB {
+	tb.Helper()
+	switch dbdriver.FromEnv() {
+	case dbdriver.SQLite:
+		return openSQLite(tb)
+	case dbdriver.Postgres:
+		return openPostgres(tb)
+	}
+	return OpenMySQL(tb)
+}
+
+// OpenSqlx Openの接続をsqlxで包んで返す
+func OpenSqlx(tb testing.TB) *sqlx.DB {
+	tb.Helper()
+	db := Open(tb)
+	return sqlx.NewDb(db, dbdriver.Name(db))
+}
+
+// OpenGorm Openの接続をGORMで包んで返す
+// configにはリポジトリと同じ設定を渡す
+func OpenGorm(tb testing.TB, config *gorm.Config) *gorm.DB {
+	tb.Helper()
+	db := Open(tb)
+
+	var dialector gorm.Dialector
+	switch dbdriver.Name(db) {
+	case dbdriver.SQLite:
+		dialector = sqlite.Dialector{Conn: db}
+	case dbdriver.Postgres:
+		dialector = gormpostgres.New(gormpostgres.Config{Conn: db})
+	default:
+		dialector = gormmysql.New(gormmysql.Config{Conn: db})
+	}
+
+	gormDB, err := gorm.Open(dialector, config)
+	if err != nil {
+		tb.Fatalf("データベース接続エラー: %v", err)
+	}
+	return gormDB
+}
+
+// OpenMySQL DB_DRIVERにかかわらず、MySQLにテスト専用のデータベースを作って接続する（MySQLのみに対応した実装用）
+// DB_HOSTが未指定の場合は、テストごとにプロセス内で起動するMySQL互換サーバー（mysqltest）のtestdbをそのまま使う
+// （go-mysql-serverでは、CREATE DATABASEで作ったデータベースに並行して書き込むと行が失われることがあるため）
+func OpenMySQL(tb testing.TB) *sql.DB {
+	tb.Helper()
+	if os.Getenv("DB_HOST") == "" {
+		db := openAndPing(tb, dbdriver.MySQL, mysqltest.DSN(tb))
+		tb.Cleanup(func() { db.Close() })
+		migrateUp(tb, db)
+		return db
+	}
+
+	dbPort := os.Getenv("DB_PORT")
+	if dbPort == "" {
+		dbPort = "3306"
+	}
+	dsn := func(dbName string) string {
+		return fmt.Sprintf("root:password@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4",
+			os.Getenv("DB_HOST"), dbPort, dbName)
+	}
+
+	admin := openAndPing(tb, dbdriver.MySQL, dsn(adminDB))
+	name := newName()
+	mustExec(tb, admin, "CREATE DATABASE "+name)
+	tb.Cleanup(func() {
+		if _, err := admin.Exec("DROP DATABASE " + name); err != nil {
+			tb.Errorf("テスト用データベースの削除エラー: %v", err)
+		}
+		admin.Close()
+	})
+
+	db := openAndPing(tb, dbdriver.MySQL, dsn(name))
+	tb.Cleanup(func() { db.Close() })
+	migrateUp(tb, db)
+	return db
+}
+
+// openPostgres PostgreSQLにテスト専用のスキーマを作り、そのスキーマを使う接続を返す
+// citextはデータベースに1つしか作れない拡張機能のため、publicスキーマに作成して検索パスに含める
+func openPostgres(tb testing.TB) *sql.DB {
+	dbHost := os.Getenv("DB_HOST")
+	if dbHost == "" {
+		dbHost = "localhost"
+	}
+	dbPort := os.Getenv("DB_PORT")
+	if dbPort == "" {
+		dbPort = "5432"
+	}
+	dsn := dbdriver.PostgresDSN(dbHost, dbPort, "postgres", "password", adminDB)
+
+	admin := openAndPing(tb, dbdriver.Postgres, dsn)
+	name := newName()
+	mustExec(tb, admin, "CREATE SCHEMA "+name)
+	tb.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + name + " CASCADE"); err != nil {
+			tb.Errorf("テスト用スキーマの削除エラー: %v", err)
+		}
+		admin.Close()
+	})
+	mustExec(tb, admin, "CREATE EXTENSION IF NOT EXISTS citext SCHEMA public")
+
+	db := openAndPing(tb, dbdriver.Postgres, dsn+"&search_path="+name+",public")
+	tb.Cleanup(func() { db.Close() })
+	migrateUp(tb, db)
+	return db
+}
+
+// openSQLite 一時ディレクトリにSQLiteのデータベースファイルを作って開く
+func openSQLite(tb testing.TB) *sql.DB {
+	db, err := dbdriver.OpenSQLite(filepath.Join(tb.TempDir(), "test.db"))
+	if err != nil {
+		tb.Fatalf("データベース接続エラー: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	migrateUp(tb, db)
+	return db
+}
+
+// migrateUp テスト用のデータベースにすべてのマイグレーションを適用する
+func migrateUp(tb testing.TB, db *sql.DB) {
+	if _, err := migrate.Up(context.Background(), db); err != nil {
+		tb.Fatalf("マイグレーションの適用エラー: %v", err)
+	}
+}
+
+// openAndPing データベースを開いて接続を確認する
+func openAndPing(tb testing.TB, driver, dsn string) *sql.DB {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		tb.Fatalf("データベース接続エラー: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		tb.Fatalf("データベースPingエラー: %v", err)
+	}
+	return db
+}
+
+// mustExec テストの準備のためのSQLを実行し、失敗した場合はテストを中断する
+func mustExec(tb testing.TB, db *sql.DB, query string) {
+	if _, err := db.Exec(query); err != nil {
+		tb.Fatalf("%s の実行エラー: %v", query, err)
+	}
+}
+
+// newName テスト用のデータベースの一意な名前を作る
+// 別のプロセスのテストと同じデータベースサーバーを使っても衝突しないよう、乱数で作る
+func newName() string {
+	return fmt.Sprintf("%s%016x", namePrefix, rand.Uint64())
+}
@@ -1,90 +1,329 @@
 package ent
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"go_sql_library/dbdriver"
+	"go_sql_library/dberr"
+	"go_sql_library/ent/predicate"
+	"go_sql_library/ent/user"
 	"go_sql_library/model"
+	"time"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
 )
 
+// createManyBatchSize CreateManyで1つのINSERT文にまとめる最大件数
+const createManyBatchSize = 1000
+
 // UserRepository entを使ったユーザーリポジトリ
-// 注意: 実際のentではコード生成を使用しますが、
-// ここではデモ用に簡略化した実装を提供しています
+// ent/schemaのスキーマから生成したクライアントでクエリを組み立てる
 type UserRepository struct {
-	db *sql.DB
+	client *Client
+
+	// inTx WithTx内のリポジトリかどうか
+	inTx bool
 }
 
 // NewUserRepository リポジトリの初期化
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(client *Client) *UserRepository {
+	return &UserRepository{client: client}
+}
+
+// NewClientFromDB 接続済みの*sql.DBからクライアントを作る
+// 接続先に合わせてMySQL、SQLiteまたはPostgreSQLの方言でクエリを組み立てる
+func NewClientFromDB(db *sql.DB) *Client {
+	d := dialect.MySQL
+	switch dbdriver.Name(db) {
+	case dbdriver.SQLite:
+		d = dialect.SQLite
+	case dbdriver.Postgres:
+		d = dialect.Postgres
+	}
+	return NewClient(Driver(entsql.OpenDB(d, db)))
+}
+
+// toModelUser entのUserをmodelのUserに変換
+func toModelUser(u *User) *model.User {
+	return &model.User{
+		ID:        u.ID,
+		Name:      u.Name,
+		Email:     u.Email,
+		Version:   u.Version,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		DeletedAt: u.DeletedAt,
+	}
 }
 
-// GetAll 全ユーザーを取得
-func (r *UserRepository) GetAll() ([]model.User, error) {
-	query := "SELECT id, name, email, created_at, updated_at FROM users ORDER BY id"
-	rows, err := r.db.Query(query)
+// toModelUsers entのUserのスライスをmodel.Userのスライスに変換
+func toModelUsers(entUsers []*User) []model.User {
+	users := make([]model.User, len(entUsers))
+	for i, u := range entUsers {
+		users[i] = *toModelUser(u)
+	}
+	return users
+}
+
+// translateError entのエラーをドメインエラーに変換
+// 一意制約違反はConstraintErrorがドライバのエラーを包んでいるため、dberrで変換できる
+func translateError(err error) error {
+	if IsNotFound(err) {
+		return model.ErrNotFound
+	}
+	return dberr.Translate(err)
+}
+
+// GetAll 削除されていない全ユーザーを取得
+func (r *UserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	entUsers, err := r.client.User.Query().
+		Where(user.DeletedAtIsNil()).
+		Order(user.ByID()).
+		All(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return toModelUsers(entUsers), nil
+}
 
-	var users []model.User
-	for rows.Next() {
-		var u model.User
-		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt)
-		if err != nil {
-			return nil, err
-		}
-		users = append(users, u)
+// List filterに一致するユーザーをIDの昇順でpage.AfterIDより後ろから最大page.Limit件取得
+// 日時は保存している値と同じくUTCで渡す
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter, page model.Page) ([]model.User, error) {
+	entUsers, err := r.client.User.Query().
+		Where(filterPredicates(filter, page)...).
+		Order(user.ByID()).
+		Limit(page.Limit).
+		All(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toModelUsers(entUsers), nil
+}
+
+// filterPredicates 検索条件からWHERE句の条件を組み立てる
+// ContainsとHasSuffixはLIKEのワイルドカードをエスケープし、値はプレースホルダで渡される
+func filterPredicates(filter model.UserFilter, page model.Page) []predicate.User {
+	ps := []predicate.User{user.IDGT(page.AfterID)}
+	if !filter.IncludeDeleted {
+		ps = append(ps, user.DeletedAtIsNil())
+	}
+
+	if filter.NameContains != "" {
+		ps = append(ps, user.NameContains(filter.NameContains))
+	}
+	if filter.EmailDomain != "" {
+		ps = append(ps, user.EmailHasSuffix("@"+filter.EmailDomain))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		ps = append(ps, user.CreatedAtGTE(filter.CreatedFrom.UTC()))
+	}
+	if !filter.CreatedTo.IsZero() {
+		ps = append(ps, user.CreatedAtLT(filter.CreatedTo.UTC()))
 	}
 
-	return users, rows.Err()
+	return ps
 }
 
-// GetByID IDでユーザーを取得
-func (r *UserRepository) GetByID(id int) (*model.User, error) {
-	query := "SELECT id, name, email, created_at, updated_at FROM users WHERE id = ?"
-	var u model.User
-	err := r.db.QueryRow(query, id).Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt)
+// GetByID IDで削除されていないユーザーを取得
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	u, err := r.client.User.Query().
+		Where(user.ID(id), user.DeletedAtIsNil()).
+		Only(ctx)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
-	return &u, nil
+	return toModelUser(u), nil
 }
 
 // Create 新規ユーザーを作成
-func (r *UserRepository) Create(name, email string) (*model.User, error) {
-	query := "INSERT INTO users (name, email) VALUES (?, ?)"
-	result, err := r.db.Exec(query, name, email)
+// versionと日時はスキーマのデフォルト値が設定される
+func (r *UserRepository) Create(ctx context.Context, name, email string) (*model.User, error) {
+	u, err := r.client.User.Create().
+		SetName(name).
+		SetEmail(email).
+		Save(ctx)
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
+	return toModelUser(u), nil
+}
+
+// CreateMany 複数のユーザーをCreateBulkでまとめて作成
+// createManyBatchSize件ごとに1つの複数行INSERT文を発行し、全体を1つのトランザクションで実行する
+func (r *UserRepository) CreateMany(ctx context.Context, users []model.NewUser) ([]int, error) {
+	ids := make([]int, 0, len(users))
+	err := r.WithTx(ctx, func(repo model.UserRepository) error {
+		tx := repo.(*UserRepository)
+		for start := 0; start < len(users); start += createManyBatchSize {
+			end := min(start+createManyBatchSize, len(users))
 
-	id, err := result.LastInsertId()
+			builders := make([]*UserCreate, 0, end-start)
+			for _, u := range users[start:end] {
+				builders = append(builders, tx.client.User.Create().SetName(u.Name).SetEmail(u.Email))
+			}
+			created, err := tx.client.User.CreateBulk(builders...).Save(ctx)
+			if err != nil {
+				return translateError(err)
+			}
+			for _, u := range created {
+				ids = append(ids, u.ID)
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return ids, nil
+}
+
+// Upsert メールアドレスをキーにユーザーを作成または名前を更新
+// OnConflictColumnsでON DUPLICATE KEY UPDATE（SQLiteとPostgreSQLではON CONFLICT）を発行する。論理削除済みのユーザーは復元する
+func (r *UserRepository) Upsert(ctx context.Context, name, email string) (*model.User, bool, error) {
+	err := r.client.User.Create().
+		SetName(name).
+		SetEmail(email).
+		OnConflictColumns(user.FieldEmail).
+		Update(func(u *UserUpsert) {
+			u.UpdateName()
+			u.ClearDeletedAt()
+			u.AddVersion(1)
+			u.UpdateUpdatedAt()
+		}).
+		Exec(ctx)
+	if err != nil {
+		return nil, false, translateError(err)
+	}
+
+	// entは影響行数を返さないため、取得し直したバージョンで判定する
+	// 既存行の更新では必ずversionを増やすため、versionが1なら挿入された行になる
+	u, err := r.client.User.Query().
+		Where(user.Email(email), user.DeletedAtIsNil()).
+		Only(ctx)
+	if err != nil {
+		return nil, false, translateError(err)
+	}
+	return toModelUser(u), u.Version == 1, nil
+}
+
+// Update 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+func (r *UserRepository) Update(ctx context.Context, id int, name, email string, version int) error {
+	affected, err := r.client.User.Update().
+		Where(user.ID(id), user.Version(version), user.DeletedAtIsNil()).
+		SetName(name).
+		SetEmail(email).
+		AddVersion(1).
+		Save(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	return r.checkVersionMatched(ctx, affected, id)
+}
+
+// Patch 指定したフィールドだけをバージョンが一致する場合のみ更新
+// SetNillable系のメソッドはnilの場合に何もしないため、指定したフィールドだけがSET句に入る
+func (r *UserRepository) Patch(ctx context.Context, id int, patch model.UserPatch, version int) error {
+	affected, err := r.client.User.Update().
+		Where(user.ID(id), user.Version(version), user.DeletedAtIsNil()).
+		SetNillableName(patch.Name).
+		SetNillableEmail(patch.Email).
+		AddVersion(1).
+		Save(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	return r.checkVersionMatched(ctx, affected, id)
+}
 
-	return r.GetByID(int(id))
+// Delete ユーザーをバージョンが一致する場合のみ論理削除
+// 他の日時と同じくUTCで保存する（SQLiteは日時を文字列として比較するため）
+func (r *UserRepository) Delete(ctx context.Context, id int, version int) error {
+	affected, err := r.client.User.Update().
+		Where(user.ID(id), user.Version(version), user.DeletedAtIsNil()).
+		SetDeletedAt(time.Now().UTC()).
+		Save(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	return r.checkVersionMatched(ctx, affected, id)
 }
 
-// Update ユーザー情報を更新
-func (r *UserRepository) Update(id int, name, email string) error {
-	query := "UPDATE users SET name = ?, email = ? WHERE id = ?"
-	_, err := r.db.Exec(query, name, email, id)
-	return err
+// checkVersionMatched バージョン付きの更新で対象行がなかった場合にその原因をエラーとして返す
+// ユーザーが存在しなければErrNotFound、存在すればバージョン不一致としてErrVersionConflictを返す
+func (r *UserRepository) checkVersionMatched(ctx context.Context, affected int, id int) error {
+	if affected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return model.ErrVersionConflict
 }
 
-// Delete ユーザーを削除
-func (r *UserRepository) Delete(id int) error {
-	query := "DELETE FROM users WHERE id = ?"
-	_, err := r.db.Exec(query, id)
-	return err
+// Restore 論理削除したユーザーを復元
+func (r *UserRepository) Restore(ctx context.Context, id int) error {
+	affected, err := r.client.User.Update().
+		Where(user.ID(id), user.DeletedAtNotNil()).
+		ClearDeletedAt().
+		AddVersion(1).
+		Save(ctx)
+	if err != nil {
+		return translateError(err)
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// Purge olderThanより前に論理削除したユーザーを物理削除し、削除件数を返す
+func (r *UserRepository) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	deleted, err := r.client.User.Delete().
+		Where(user.DeletedAtLT(olderThan.UTC())).
+		Exec(ctx)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return int64(deleted), nil
+}
+
+// WithTx fnをトランザクション内で実行する
+// fnがエラーを返すかパニックした場合はロールバックし、それ以外はコミットする
+func (r *UserRepository) WithTx(ctx context.Context, fn func(repo model.UserRepository) error) error {
+	// 既にトランザクション内であれば同じトランザクションに参加する
+	if r.inTx {
+		return fn(r)
+	}
+
+	tx, err := r.client.Tx(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	// tx.Client()が返すクライアントのクエリはすべてこのトランザクションで実行される
+	if err := fn(&UserRepository{client: tx.Client(), inTx: true}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
 }
 
 // Close データベース接続を閉じる
+// トランザクション内のリポジトリは接続を所有しないため何もしない
 func (r *UserRepository) Close() error {
-	if r.db == nil {
-		return errors.New("database connection is nil")
+	if r.inTx {
+		return nil
 	}
-	return r.db.Close()
+	return r.client.Close()
 }
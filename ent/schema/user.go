@@ -0,0 +1,45 @@
+// Package schema entのスキーマ定義
+// テーブルはマイグレーション（migrateパッケージ）で作成するため、ここではマイグレーションのusersテーブルに合わせて定義する
+package schema
+
+import (
+	"time"
+
+	"entgo.io/ent"
+	"entgo.io/ent/schema/field"
+)
+
+// User usersテーブルのスキーマ
+type User struct {
+	ent.Schema
+}
+
+// Fields usersテーブルのカラム
+func (User) Fields() []ent.Field {
+	return []ent.Field{
+		field.String("name").
+			MaxLen(100),
+		field.String("email").
+			MaxLen(100).
+			Unique(),
+		// 楽観的ロック用のバージョン（作成時は1で、更新のたびに増える）
+		field.Int("version").
+			Default(1),
+		field.Time("created_at").
+			Default(nowUTC).
+			Immutable(),
+		field.Time("updated_at").
+			Default(nowUTC).
+			UpdateDefault(nowUTC),
+		// 論理削除した日時（削除されていない場合はNULL）
+		field.Time("deleted_at").
+			Optional().
+			Nillable(),
+	}
+}
+
+// nowUTC 作成日時と更新日時に設定する現在時刻
+// SQLiteは日時を文字列として比較するため、どの接続先でもUTCに揃える
+func nowUTC() time.Time {
+	return time.Now().UTC()
+}
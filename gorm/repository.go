@@ -1,10 +1,15 @@
 package gorm
 
 import (
+	"context"
+	"errors"
+	"go_sql_library/dbdriver"
+	"go_sql_library/dberr"
 	"go_sql_library/model"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // User GORMモデル（model.Userとは別に定義）
@@ -12,8 +17,10 @@ type User struct {
 	ID        int       `gorm:"primaryKey"`
 	Name      string    `gorm:"type:varchar(100);not null"`
 	Email     string    `gorm:"type:varchar(100);not null;uniqueIndex"`
+	Version   int       `gorm:"not null;default:1"`
 	CreatedAt time.Time `gorm:"autoCreateTime"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt
 }
 
 // TableName テーブル名を指定
@@ -21,9 +28,28 @@ func (User) TableName() string {
 	return "users"
 }
 
+// NewConfig リポジトリで使うGORMの設定
+// 1文だけの書き込みを暗黙のトランザクションで包まない（複数の文をまとめる場合はWithTxを使う）
+// 他の実装と同じく1文ずつ実行されるため、ベンチマークも同じ条件で比較できる
+// 作成日時などに設定する現在時刻はUTCにする（SQLiteは日時を文字列として比較するため、タイムゾーンを揃える）
+func NewConfig() *gorm.Config {
+	return &gorm.Config{
+		SkipDefaultTransaction: true,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+// createManyBatchSize CreateManyで1つのINSERT文にまとめる最大件数
+const createManyBatchSize = 1000
+
 // UserRepository GORMを使ったユーザーリポジトリ
 type UserRepository struct {
 	db *gorm.DB
+
+	// inTx WithTx内のリポジトリかどうか
+	inTx bool
 }
 
 // NewUserRepository リポジトリの初期化
@@ -33,65 +59,269 @@ func NewUserRepository(db *gorm.DB) *UserRepository {
 
 // toModelUser GORM UserをmodelのUserに変換
 func toModelUser(u *User) *model.User {
-	return &model.User{
+	user := &model.User{
 		ID:        u.ID,
 		Name:      u.Name,
 		Email:     u.Email,
+		Version:   u.Version,
 		CreatedAt: u.CreatedAt,
 		UpdatedAt: u.UpdatedAt,
 	}
+	if u.DeletedAt.Valid {
+		deletedAt := u.DeletedAt.Time
+		user.DeletedAt = &deletedAt
+	}
+	return user
 }
 
-// GetAll 全ユーザーを取得
-func (r *UserRepository) GetAll() ([]model.User, error) {
+// toModelUsers GORM Userのスライスをmodel.Userのスライスに変換
+func toModelUsers(gormUsers []User) []model.User {
+	users := make([]model.User, len(gormUsers))
+	for i, u := range gormUsers {
+		users[i] = *toModelUser(&u)
+	}
+	return users
+}
+
+// translateError GORMのエラーをドメインエラーに変換
+func translateError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.ErrNotFound
+	}
+	return dberr.Translate(err)
+}
+
+// GetAll 削除されていない全ユーザーを取得
+// gorm.DeletedAtを持つモデルは論理削除済みの行が自動的に除外される
+func (r *UserRepository) GetAll(ctx context.Context) ([]model.User, error) {
 	var gormUsers []User
-	if err := r.db.Order("id").Find(&gormUsers).Error; err != nil {
+	if err := r.db.WithContext(ctx).Order("id").Find(&gormUsers).Error; err != nil {
 		return nil, err
 	}
+	return toModelUsers(gormUsers), nil
+}
 
-	users := make([]model.User, len(gormUsers))
-	for i, u := range gormUsers {
-		users[i] = *toModelUser(&u)
+// List filterに一致するユーザーをIDの昇順でpage.AfterIDより後ろから最大page.Limit件取得
+// 日時は保存している値と同じくUTCで渡す
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter, page model.Page) ([]model.User, error) {
+	q := r.db.WithContext(ctx).Where("id > ?", page.AfterID)
+	if filter.IncludeDeleted {
+		q = q.Unscoped()
 	}
-	return users, nil
+	if filter.NameContains != "" {
+		q = q.Where("name LIKE ? ESCAPE '"+model.LikeEscapeChar+"'", "%"+model.EscapeLike(filter.NameContains)+"%")
+	}
+	if filter.EmailDomain != "" {
+		q = q.Where("email LIKE ? ESCAPE '"+model.LikeEscapeChar+"'", "%@"+model.EscapeLike(filter.EmailDomain))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		q = q.Where("created_at >= ?", filter.CreatedFrom.UTC())
+	}
+	if !filter.CreatedTo.IsZero() {
+		q = q.Where("created_at < ?", filter.CreatedTo.UTC())
+	}
+
+	var gormUsers []User
+	if err := q.Order("id").Limit(page.Limit).Find(&gormUsers).Error; err != nil {
+		return nil, err
+	}
+	return toModelUsers(gormUsers), nil
 }
 
-// GetByID IDでユーザーを取得
-func (r *UserRepository) GetByID(id int) (*model.User, error) {
+// GetByID IDで削除されていないユーザーを取得
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
 	var u User
-	if err := r.db.First(&u, id).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).First(&u, id).Error; err != nil {
+		return nil, translateError(err)
 	}
 	return toModelUser(&u), nil
 }
 
 // Create 新規ユーザーを作成
-func (r *UserRepository) Create(name, email string) (*model.User, error) {
+func (r *UserRepository) Create(ctx context.Context, name, email string) (*model.User, error) {
 	u := User{
-		Name:  name,
-		Email: email,
+		Name:    name,
+		Email:   email,
+		Version: 1,
 	}
-	if err := r.db.Create(&u).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Create(&u).Error; err != nil {
+		return nil, translateError(err)
 	}
 	return toModelUser(&u), nil
 }
 
-// Update ユーザー情報を更新
-func (r *UserRepository) Update(id int, name, email string) error {
-	return r.db.Model(&User{}).Where("id = ?", id).Updates(User{
-		Name:  name,
-		Email: email,
-	}).Error
+// CreateMany 複数のユーザーをCreateInBatchesでまとめて作成
+// createManyBatchSize件ごとに1つの複数行INSERT文を発行し、全体を1つのトランザクションで実行する
+func (r *UserRepository) CreateMany(ctx context.Context, users []model.NewUser) ([]int, error) {
+	if len(users) == 0 {
+		return []int{}, nil
+	}
+
+	gormUsers := make([]User, len(users))
+	for i, u := range users {
+		gormUsers[i] = User{Name: u.Name, Email: u.Email, Version: 1}
+	}
+
+	err := r.WithTx(ctx, func(repo model.UserRepository) error {
+		tx := repo.(*UserRepository)
+		return tx.db.WithContext(ctx).CreateInBatches(&gormUsers, createManyBatchSize).Error
+	})
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	ids := make([]int, len(gormUsers))
+	for i, u := range gormUsers {
+		ids[i] = u.ID
+	}
+	return ids, nil
 }
 
-// Delete ユーザーを削除
-func (r *UserRepository) Delete(id int) error {
-	return r.db.Delete(&User{}, id).Error
+// Upsert メールアドレスをキーにユーザーを作成または名前を更新
+// 論理削除済みのユーザーは復元する
+// clause.AssignmentColumnsは接続先に合わせてVALUES(name)（MySQL）またはexcluded.name（SQLite、PostgreSQL）になる
+// PostgreSQLではexcludedのversionと区別できないため、既存行のversionはテーブル名で指定する
+func (r *UserRepository) Upsert(ctx context.Context, name, email string) (*model.User, bool, error) {
+	u := User{
+		Name:    name,
+		Email:   email,
+		Version: 1,
+	}
+	q := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "email"}},
+		DoUpdates: append(clause.AssignmentColumns([]string{"name"}), clause.Assignments(map[string]any{
+			"deleted_at": nil,
+			"version":    gorm.Expr("users.version + 1"),
+		})...),
+	})
+	returning := r.db.Dialector.Name() != dbdriver.MySQL
+	if returning {
+		// SQLiteとPostgreSQLの影響行数は挿入でも更新でも1になるため、保存後のバージョンを返させて判定する
+		q = q.Clauses(clause.Returning{Columns: []clause.Column{{Name: "version"}}})
+	}
+	result := q.Create(&u)
+	if result.Error != nil {
+		return nil, false, translateError(result.Error)
+	}
+
+	// MySQLの影響行数は挿入時は1、既存行の更新時は2になる
+	// 既存行の更新では必ずversionを増やすため、SQLiteとPostgreSQLではversionが1なら挿入された行になる
+	inserted := result.RowsAffected == 1
+	if returning {
+		inserted = u.Version == 1
+	}
+
+	// 更新時は既存行のIDが返らないため、メールアドレスで取得し直す
+	var saved User
+	if err := r.db.WithContext(ctx).First(&saved, "email = ?", email).Error; err != nil {
+		return nil, false, translateError(err)
+	}
+	return toModelUser(&saved), inserted, nil
+}
+
+// Update 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+// 構造体を渡すUpdatesは空文字を書き込まないため、他の実装と同じくmapで全カラムを更新する
+func (r *UserRepository) Update(ctx context.Context, id int, name, email string, version int) error {
+	result := r.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND version = ?", id, version).
+		Updates(map[string]any{
+			"name":    name,
+			"email":   email,
+			"version": gorm.Expr("version + 1"),
+		})
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// Patch 指定したフィールドだけをバージョンが一致する場合のみ更新
+// 構造体を渡すUpdatesはゼロ値を書き込まないため、mapで更新するカラムを明示する
+func (r *UserRepository) Patch(ctx context.Context, id int, patch model.UserPatch, version int) error {
+	values := map[string]any{
+		"version": gorm.Expr("version + 1"),
+	}
+	if patch.Name != nil {
+		values["name"] = *patch.Name
+	}
+	if patch.Email != nil {
+		values["email"] = *patch.Email
+	}
+
+	result := r.db.WithContext(ctx).Model(&User{}).
+		Where("id = ? AND version = ?", id, version).
+		Updates(values)
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// Delete ユーザーをバージョンが一致する場合のみ論理削除
+// gorm.DeletedAtを持つモデルのDeleteはdeleted_atを設定するUPDATEになる
+func (r *UserRepository) Delete(ctx context.Context, id int, version int) error {
+	result := r.db.WithContext(ctx).Where("version = ?", version).Delete(&User{}, id)
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// checkVersionMatched バージョン付きの更新で対象行がなかった場合にその原因をエラーとして返す
+// ユーザーが存在しなければErrNotFound、存在すればバージョン不一致としてErrVersionConflictを返す
+func (r *UserRepository) checkVersionMatched(ctx context.Context, result *gorm.DB, id int) error {
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return model.ErrVersionConflict
+}
+
+// Restore 論理削除したユーザーを復元
+func (r *UserRepository) Restore(ctx context.Context, id int) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]any{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// Purge olderThanより前に論理削除したユーザーを物理削除し、削除件数を返す
+func (r *UserRepository) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at < ?", olderThan.UTC()).
+		Delete(&User{})
+	if result.Error != nil {
+		return 0, translateError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// WithTx fnをトランザクション内で実行する
+// コミットとロールバック（パニック時を含む）はdb.Transactionに任せる
+func (r *UserRepository) WithTx(ctx context.Context, fn func(repo model.UserRepository) error) error {
+	// 既にトランザクション内であれば同じトランザクションに参加する
+	// （db.Transactionを入れ子で呼ぶとSAVEPOINTになるため使わない）
+	if r.inTx {
+		return fn(r)
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&UserRepository{db: tx, inTx: true})
+	})
 }
 
 // Close データベース接続を閉じる
+// トランザクション内のリポジトリは接続を所有しないため何もしない
 func (r *UserRepository) Close() error {
+	if r.inTx {
+		return nil
+	}
 	sqlDB, err := r.db.DB()
 	if err != nil {
 		return err
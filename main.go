@@ -1,26 +1,51 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"go_sql_library/model"
+	bunRepo "go_sql_library/bun"
+	"go_sql_library/dbdriver"
 	entRepo "go_sql_library/ent"
 	gormRepo "go_sql_library/gorm"
-	standardRepo "go_sql_library/standard"
+	memoryRepo "go_sql_library/memory"
+	"go_sql_library/middleware"
+	"go_sql_library/migrate"
+	"go_sql_library/model"
+	"go_sql_library/schemadrift"
+	sqlcRepo "go_sql_library/sqlc"
 	sqlxRepo "go_sql_library/sqlx"
+	squirrelRepo "go_sql_library/squirrel"
+	standardRepo "go_sql_library/standard"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/glebarez/sqlite"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+// ページネーションの件数設定
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// listQueryKeys 指定されるとページ単位の一覧取得になるクエリパラメータ
+var listQueryKeys = []string{"limit", "cursor", "name", "email_domain", "created_from", "created_to", "include_deleted"}
+
 var repo model.UserRepository
 var libraryType string
 
@@ -35,37 +60,81 @@ func main() {
 	if libraryType == "" {
 		libraryType = "standard" // デフォルトは標準ライブラリ
 	}
+	dbDriver := dbdriver.FromEnv()
+	dbPath := os.Getenv("DB_PATH") // SQLiteのデータベースファイル
+	if dbPath == "" {
+		dbPath = "testdb.sqlite"
+	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4",
-		dbUser, dbPassword, dbHost, dbPort, dbName)
+	// 接続先ごとのデータソース（MySQLとPostgreSQLは接続文字列、SQLiteはファイルのパス）
+	var dataSource string
+	switch dbDriver {
+	case dbdriver.SQLite:
+		dataSource = dbPath
+	case dbdriver.Postgres:
+		dataSource = dbdriver.PostgresDSN(dbHost, dbPort, dbUser, dbPassword, dbName)
+	default:
+		dataSource = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4",
+			dbUser, dbPassword, dbHost, dbPort, dbName)
+	}
 
-	// 使用するライブラリによって初期化方法を変更
-	var err error
-	switch libraryType {
-	case "standard":
-		repo, err = initStandard(dsn)
-	case "sqlx":
-		repo, err = initSqlx(dsn)
-	case "gorm":
-		repo, err = initGorm(dsn)
-	case "ent":
-		repo, err = initEnt(dsn)
+	// go run . migrate up|down|status でマイグレーションだけを実行する
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:], dbDriver, dataSource)
+		return
+	}
+
+	// go run . drift でモデルとテーブル定義のずれを検出する
+	if len(os.Args) > 1 && os.Args[1] == "drift" {
+		runDrift(dbDriver, dataSource)
+		return
+	}
+
+	// リポジトリを包むミドルウェア（カンマ区切りで指定した順に外側から包む）
+	middlewares, err := repositoryMiddlewares(os.Getenv("REPOSITORY_MIDDLEWARE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 使用するデータベースとライブラリによって初期化方法を変更
+	switch {
+	case libraryType == "memory":
+		// データベースを使わないため、DB_DRIVERは見ない
+		repo, err = initMemory()
+	case dbDriver == dbdriver.SQLite:
+		repo, err = initSQLite(libraryType, dataSource)
+	case dbDriver == dbdriver.Postgres:
+		repo, err = initPostgres(libraryType, dataSource)
+	case dbDriver == dbdriver.MySQL:
+		repo, err = initMySQL(libraryType, dataSource)
 	default:
-		log.Fatalf("未対応のライブラリタイプ: %s", libraryType)
+		log.Fatalf("未対応のデータベース: %s", dbDriver)
 	}
 
 	if err != nil {
 		log.Fatal("データベース初期化エラー:", err)
 	}
+	// どのライブラリでも同じミドルウェアで包む（Closeはミドルウェアを通って元のリポジトリに届く）
+	repo = model.Chain(repo, middlewares...)
 	defer repo.Close()
 
-	log.Printf("データベース接続成功！（ライブラリ: %s）\n", libraryType)
+	// 起動時に未適用のマイグレーションを適用する
+	if libraryType != "memory" {
+		if err := migrateUp(dbDriver, dataSource); err != nil {
+			log.Fatal("マイグレーションエラー:", err)
+		}
+	}
+
+	log.Printf("データベース接続成功！（ライブラリ: %s, データベース: %s）\n", libraryType, dbDriver)
 
 	// ルーティング設定
 	http.HandleFunc("/", homeHandler)
 	http.HandleFunc("/ping", pingHandler)
 	http.HandleFunc("/users", usersHandler)
 	http.HandleFunc("/users/", userHandler)
+	http.HandleFunc("PUT /users/by-email/{email}", upsertByEmailHandler)
+	http.HandleFunc("POST /users/{id}/restore", restoreHandler)
+	http.HandleFunc("POST /users/purge", purgeHandler)
 
 	log.Println("サーバーを起動します: http://localhost:8080")
 	if err := http.ListenAndServe(":8080", nil); err != nil {
@@ -73,11 +142,17 @@ func main() {
 	}
 }
 
-func initStandard(dsn string) (model.UserRepository, error) {
+// openDB driverのデータベースへ接続する（SQLiteの場合はdataSourceのファイルを開く）
+// 起動直後のデータベースのコンテナを待つため、接続できるまで再試行する
+func openDB(driver, dataSource string) (*sql.DB, error) {
+	if driver == dbdriver.SQLite {
+		return dbdriver.OpenSQLite(dataSource)
+	}
+
 	var db *sql.DB
 	var err error
 	for i := 0; i < 30; i++ {
-		db, err = sql.Open("mysql", dsn)
+		db, err = sql.Open(driver, dataSource)
 		if err == nil {
 			err = db.Ping()
 			if err == nil {
@@ -90,68 +165,309 @@ func initStandard(dsn string) (model.UserRepository, error) {
 	if err != nil {
 		return nil, err
 	}
-	return standardRepo.NewUserRepository(db), nil
+	return db, nil
 }
 
-func initSqlx(dsn string) (model.UserRepository, error) {
-	var db *sqlx.DB
-	var err error
-	for i := 0; i < 30; i++ {
-		db, err = sqlx.Open("mysql", dsn)
-		if err == nil {
-			err = db.Ping()
-			if err == nil {
-				break
+// migrateUp 未適用のマイグレーションをすべて適用する
+func migrateUp(driver, dataSource string) error {
+	db, err := openDB(driver, dataSource)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	applied, err := migrate.Up(context.Background(), db)
+	for _, m := range applied {
+		log.Printf("マイグレーションを適用しました: %04d_%s", m.Version, m.Name)
+	}
+	return err
+}
+
+// runMigrate migrateサブコマンドを実行する
+// up: 未適用のマイグレーションをすべて適用、down: 最後に適用したマイグレーションを1つ戻す、status: 適用状況を表示
+func runMigrate(args []string, driver, dataSource string) {
+	if len(args) != 1 {
+		log.Fatal("使い方: migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		if err := migrateUp(driver, dataSource); err != nil {
+			log.Fatal("マイグレーションエラー:", err)
+		}
+
+	case "down":
+		db, err := openDB(driver, dataSource)
+		if err != nil {
+			log.Fatal("データベース接続エラー:", err)
+		}
+		defer db.Close()
+
+		m, err := migrate.Down(context.Background(), db)
+		if err != nil {
+			log.Fatal("マイグレーションエラー:", err)
+		}
+		if m == nil {
+			log.Println("戻すマイグレーションはありません")
+			return
+		}
+		log.Printf("マイグレーションを戻しました: %04d_%s", m.Version, m.Name)
+
+	case "status":
+		db, err := openDB(driver, dataSource)
+		if err != nil {
+			log.Fatal("データベース接続エラー:", err)
+		}
+		defer db.Close()
+
+		statuses, err := migrate.List(context.Background(), db)
+		if err != nil {
+			log.Fatal("マイグレーションエラー:", err)
+		}
+		for _, s := range statuses {
+			appliedAt := "未適用"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.DateTime)
 			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, appliedAt)
 		}
-		log.Printf("データベース接続待機中... (%d/30)", i+1)
-		time.Sleep(time.Second)
+
+	default:
+		log.Fatalf("未対応のmigrateコマンド: %s（up、down、statusから選択）", args[0])
+	}
+}
+
+// runDrift driftサブコマンドを実行する
+// モデル（gorm.User、model.User）とテーブル定義のずれを表示し、ずれがあれば終了コード1で終了する
+func runDrift(driver, dataSource string) {
+	db, err := openDB(driver, dataSource)
+	if err != nil {
+		log.Fatal("データベース接続エラー:", err)
 	}
+	defer db.Close()
+
+	problems, err := schemadrift.Check(context.Background(), db)
 	if err != nil {
-		return nil, err
+		log.Fatal("スキーマドリフトの検出エラー:", err)
+	}
+	for _, p := range problems {
+		fmt.Println(p)
 	}
-	return sqlxRepo.NewUserRepository(db), nil
+	if len(problems) > 0 {
+		log.Fatalf("モデルとテーブル定義に%d件のずれがあります", len(problems))
+	}
+	log.Println("モデルとテーブル定義は一致しています")
 }
 
-func initGorm(dsn string) (model.UserRepository, error) {
-	var db *gorm.DB
-	var err error
-	for i := 0; i < 30; i++ {
-		db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{})
-		if err == nil {
-			sqlDB, _ := db.DB()
-			err = sqlDB.Ping()
-			if err == nil {
-				break
-			}
+// repositoryMiddlewares カンマ区切りの名前から、リポジトリを包むミドルウェアを指定順に作成
+// logging: 呼び出しと結果をログに出力、timing: 実行時間をログに出力
+func repositoryMiddlewares(names string) ([]model.Middleware, error) {
+	var middlewares []model.Middleware
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case "logging":
+			middlewares = append(middlewares, middleware.Logging(log.Default()))
+		case "timing":
+			middlewares = append(middlewares, middleware.Timing(func(method string, elapsed time.Duration) {
+				log.Printf("UserRepository.%s: %s", method, elapsed)
+			}))
+		default:
+			return nil, fmt.Errorf("未対応のミドルウェア: %s（logging、timingから選択）", name)
 		}
-		log.Printf("データベース接続待機中... (%d/30)", i+1)
-		time.Sleep(time.Second)
 	}
+	return middlewares, nil
+}
+
+// initMySQL MySQLに接続し、ライブラリごとのリポジトリを作成
+func initMySQL(libraryType, dsn string) (model.UserRepository, error) {
+	db, err := openDB(dbdriver.MySQL, dsn)
 	if err != nil {
 		return nil, err
 	}
-	return gormRepo.NewUserRepository(db), nil
+
+	switch libraryType {
+	case "standard":
+		return standardRepo.NewUserRepository(db), nil
+	case "sqlx":
+		return sqlxRepo.NewUserRepository(sqlx.NewDb(db, dbdriver.MySQL)), nil
+	case "gorm":
+		gormDB, err := gorm.Open(mysql.New(mysql.Config{Conn: db}), gormRepo.NewConfig())
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return gormRepo.NewUserRepository(gormDB), nil
+	case "ent":
+		// 接続確認済みの*sql.DBをentのドライバで包んでクライアントを作る
+		return entRepo.NewUserRepository(entRepo.NewClientFromDB(db)), nil
+	case "sqlc":
+		return sqlcRepo.NewUserRepository(db), nil
+	case "bun":
+		return bunRepo.NewUserRepository(bun.NewDB(db, mysqldialect.New(mysqldialect.WithTimeLocation("UTC")))), nil
+	case "squirrel":
+		return squirrelRepo.NewUserRepository(db), nil
+	}
+	db.Close()
+	return nil, fmt.Errorf("未対応のライブラリタイプ: %s", libraryType)
 }
 
-func initEnt(dsn string) (model.UserRepository, error) {
-	var db *sql.DB
-	var err error
-	for i := 0; i < 30; i++ {
-		db, err = sql.Open("mysql", dsn)
-		if err == nil {
-			err = db.Ping()
-			if err == nil {
-				break
-			}
+// initSQLite SQLiteのデータベースファイルを開き、ライブラリごとのリポジトリを作成
+// SQLiteに対応しているのはstandard、sqlx、gorm、entのみ
+func initSQLite(libraryType, path string) (model.UserRepository, error) {
+	db, err := openDB(dbdriver.SQLite, path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch libraryType {
+	case "standard":
+		return standardRepo.NewUserRepository(db), nil
+	case "sqlx":
+		return sqlxRepo.NewUserRepository(sqlx.NewDb(db, dbdriver.SQLite)), nil
+	case "gorm":
+		gormDB, err := gorm.Open(sqlite.Dialector{Conn: db}, gormRepo.NewConfig())
+		if err != nil {
+			db.Close()
+			return nil, err
 		}
-		log.Printf("データベース接続待機中... (%d/30)", i+1)
-		time.Sleep(time.Second)
+		return gormRepo.NewUserRepository(gormDB), nil
+	case "ent":
+		return entRepo.NewUserRepository(entRepo.NewClientFromDB(db)), nil
 	}
+	db.Close()
+	return nil, fmt.Errorf("SQLiteに対応していないライブラリタイプ: %s", libraryType)
+}
+
+// initPostgres PostgreSQLに接続し、ライブラリごとのリポジトリを作成
+// PostgreSQLに対応しているのはstandard、sqlx、gorm、entのみ
+func initPostgres(libraryType, dsn string) (model.UserRepository, error) {
+	db, err := openDB(dbdriver.Postgres, dsn)
 	if err != nil {
 		return nil, err
 	}
-	return entRepo.NewUserRepository(db), nil
+
+	switch libraryType {
+	case "standard":
+		return standardRepo.NewUserRepository(db), nil
+	case "sqlx":
+		return sqlxRepo.NewUserRepository(sqlx.NewDb(db, dbdriver.Postgres)), nil
+	case "gorm":
+		gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), gormRepo.NewConfig())
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return gormRepo.NewUserRepository(gormDB), nil
+	case "ent":
+		return entRepo.NewUserRepository(entRepo.NewClientFromDB(db)), nil
+	}
+	db.Close()
+	return nil, fmt.Errorf("PostgreSQLに対応していないライブラリタイプ: %s", libraryType)
+}
+
+// initMemory データベースを使わないメモリ上のリポジトリを作成
+// マイグレーションと同じサンプルデータを入れておく（再起動するとデータは消える）
+func initMemory() (model.UserRepository, error) {
+	repo := memoryRepo.NewUserRepository()
+	_, err := repo.CreateMany(context.Background(), []model.NewUser{
+		{Name: "山田太郎", Email: "yamada@example.com"},
+		{Name: "佐藤花子", Email: "sato@example.com"},
+		{Name: "鈴木一郎", Email: "suzuki@example.com"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// writeError リポジトリのエラーをHTTPステータスに変換して返す
+func writeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, model.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, model.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// decodeMergePatch JSON Merge Patch（RFC 7396）形式のリクエストボディを部分更新の内容に変換
+// 省略したフィールドは変更しない。nameとemailは必須項目のためnullによる削除は受け付けない
+func decodeMergePatch(r *http.Request) (model.UserPatch, error) {
+	var patch model.UserPatch
+
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		return patch, err
+	}
+
+	for key, raw := range fields {
+		var target **string
+		switch key {
+		case "name":
+			target = &patch.Name
+		case "email":
+			target = &patch.Email
+		default:
+			return patch, fmt.Errorf("unknown field: %s", key)
+		}
+
+		if string(raw) == "null" {
+			return patch, fmt.Errorf("%s cannot be null", key)
+		}
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return patch, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		*target = &v
+	}
+
+	return patch, nil
+}
+
+// errPreconditionRequired If-Matchヘッダーで取得時のETagを指定しなかった
+var errPreconditionRequired = errors.New(`If-Matchヘッダーに取得時のETagを指定してください（例: If-Match: "1"）`)
+
+// writeConditionalError If-Matchで指定したバージョンが一致しなかった場合は412、
+// If-Matchを指定しなかった場合は428、それ以外はwriteErrorと同じ
+func writeConditionalError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errPreconditionRequired):
+		http.Error(w, err.Error(), http.StatusPreconditionRequired)
+	case errors.Is(err, model.ErrVersionConflict):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	default:
+		writeError(w, err)
+	}
+}
+
+// etag バージョンからETagヘッダーの値を作る
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// expectedVersion If-Matchヘッダーから更新・削除で期待するバージョンを求める。形式が不正な値はどのバージョンとも一致しない
+// ヘッダーがないか*の場合は、現在のバージョンを読んでから更新すると他の更新と競合して失敗することがあるため、
+// 期待するバージョンを決められないとしてerrPreconditionRequiredを返す
+func expectedVersion(r *http.Request) (int, error) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return 0, errPreconditionRequired
+	}
+
+	s, ok := strings.CutPrefix(ifMatch, `"`)
+	if ok {
+		s, ok = strings.CutSuffix(s, `"`)
+	}
+	version, err := strconv.Atoi(s)
+	if !ok || err != nil {
+		// バージョンは1から始まるため0は必ず不一致になる
+		return 0, nil
+	}
+	return version, nil
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) {
@@ -160,9 +476,15 @@ func homeHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "利用可能なエンドポイント:\n")
 	fmt.Fprintf(w, "  GET  /           - このメッセージ\n")
 	fmt.Fprintf(w, "  GET  /ping       - ヘルスチェック\n")
-	fmt.Fprintf(w, "  GET  /users      - 全ユーザー取得\n")
+	fmt.Fprintf(w, "  GET  /users      - 全ユーザー取得（?limit=&cursor= でページ取得）\n")
+	fmt.Fprintf(w, "                     検索: ?name=&email_domain=&created_from=&created_to=（RFC3339）\n")
+	fmt.Fprintf(w, "                     削除済みを含める: ?include_deleted=true\n")
 	fmt.Fprintf(w, "  GET  /users/{id} - 特定ユーザー取得\n")
 	fmt.Fprintf(w, "  POST /users      - ユーザー作成（name, email必須）\n")
+	fmt.Fprintf(w, "  PATCH /users/{id}           - ユーザーの部分更新（JSON Merge Patch）\n")
+	fmt.Fprintf(w, "  PUT  /users/by-email/{email} - メールアドレスで作成または更新（name必須）\n")
+	fmt.Fprintf(w, "  POST /users/{id}/restore     - 論理削除したユーザーを復元\n")
+	fmt.Fprintf(w, "  POST /users/purge?older_than=720h - 指定期間より前に論理削除したユーザーを物理削除\n")
 }
 
 func pingHandler(w http.ResponseWriter, r *http.Request) {
@@ -174,9 +496,18 @@ func usersHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "GET":
-		users, err := repo.GetAll()
+		// ページネーションや検索条件が指定された場合はページ単位で返す
+		query := r.URL.Query()
+		for _, key := range listQueryKeys {
+			if query.Has(key) {
+				listUsers(w, r)
+				return
+			}
+		}
+
+		users, err := repo.GetAll(r.Context())
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 		json.NewEncoder(w).Encode(users)
@@ -191,9 +522,9 @@ func usersHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		user, err := repo.Create(input.Name, input.Email)
+		user, err := repo.Create(r.Context(), input.Name, input.Email)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, err)
 			return
 		}
 
@@ -205,6 +536,90 @@ func usersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// listUsers キーセットページネーションでユーザー一覧を返す
+func listUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultPageLimit
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxPageLimit {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	filter, err := parseUserFilter(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 次ページの有無を判定するため1件多く取得する
+	page := model.Page{Limit: limit + 1}
+	if s := query.Get("cursor"); s != "" {
+		afterID, err := model.DecodeCursor(s)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		page.AfterID = afterID
+	}
+
+	users, err := repo.List(r.Context(), filter, page)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp := struct {
+		Users      []model.User `json:"users"`
+		NextCursor string       `json:"next_cursor,omitempty"`
+	}{Users: users}
+	if len(users) > limit {
+		resp.Users = users[:limit]
+		resp.NextCursor = model.EncodeCursor(users[limit-1].ID)
+	}
+	if resp.Users == nil {
+		resp.Users = []model.User{}
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseUserFilter クエリパラメータから検索条件を組み立てる
+func parseUserFilter(query url.Values) (model.UserFilter, error) {
+	filter := model.UserFilter{
+		NameContains: query.Get("name"),
+		EmailDomain:  query.Get("email_domain"),
+	}
+
+	if s := query.Get("created_from"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_from: %w", err)
+		}
+		filter.CreatedFrom = t
+	}
+	if s := query.Get("created_to"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_to: %w", err)
+		}
+		filter.CreatedTo = t
+	}
+
+	if s := query.Get("include_deleted"); s != "" {
+		includeDeleted, err := strconv.ParseBool(s)
+		if err != nil {
+			return filter, fmt.Errorf("invalid include_deleted: %w", err)
+		}
+		filter.IncludeDeleted = includeDeleted
+	}
+
+	return filter, nil
+}
+
 func userHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
@@ -218,11 +633,12 @@ func userHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case "GET":
-		user, err := repo.GetByID(id)
+		user, err := repo.GetByID(r.Context(), id)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, err)
 			return
 		}
+		w.Header().Set("ETag", etag(user.Version))
 		json.NewEncoder(w).Encode(user)
 
 	case "PUT":
@@ -235,17 +651,60 @@ func userHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := repo.Update(id, input.Name, input.Email); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		version, err := expectedVersion(r)
+		if err != nil {
+			writeConditionalError(w, err)
 			return
 		}
 
-		user, _ := repo.GetByID(id)
+		if err := repo.Update(r.Context(), id, input.Name, input.Email, version); err != nil {
+			writeConditionalError(w, err)
+			return
+		}
+
+		user, err := repo.GetByID(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("ETag", etag(user.Version))
+		json.NewEncoder(w).Encode(user)
+
+	case "PATCH":
+		patch, err := decodeMergePatch(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		version, err := expectedVersion(r)
+		if err != nil {
+			writeConditionalError(w, err)
+			return
+		}
+
+		if err := repo.Patch(r.Context(), id, patch, version); err != nil {
+			writeConditionalError(w, err)
+			return
+		}
+
+		user, err := repo.GetByID(r.Context(), id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("ETag", etag(user.Version))
 		json.NewEncoder(w).Encode(user)
 
 	case "DELETE":
-		if err := repo.Delete(id); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		version, err := expectedVersion(r)
+		if err != nil {
+			writeConditionalError(w, err)
+			return
+		}
+
+		if err := repo.Delete(r.Context(), id, version); err != nil {
+			writeConditionalError(w, err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
@@ -254,3 +713,72 @@ func userHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
+
+// upsertByEmailHandler メールアドレスをキーにユーザーを作成または更新
+// 作成した場合は201、既存ユーザーを更新した場合は200を返す
+func upsertByEmailHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, inserted, err := repo.Upsert(r.Context(), input.Name, r.PathValue("email"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if inserted {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(user)
+}
+
+// restoreHandler 論理削除したユーザーを復元
+func restoreHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := repo.Restore(r.Context(), id); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	user, err := repo.GetByID(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(user)
+}
+
+// purgeHandler older_thanで指定した期間より前に論理削除したユーザーを物理削除
+func purgeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	olderThan, err := time.ParseDuration(r.URL.Query().Get("older_than"))
+	if err != nil || olderThan < 0 {
+		http.Error(w, "Invalid older_than", http.StatusBadRequest)
+		return
+	}
+
+	purged, err := repo.Purge(r.Context(), time.Now().Add(-olderThan))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(struct {
+		Purged int64 `json:"purged"`
+	}{Purged: purged})
+}
@@ -0,0 +1,17 @@
+//go:build benchmark
+// +build benchmark
+
+package memory
+
+import (
+	"go_sql_library/model"
+	"go_sql_library/repositorybench"
+	"testing"
+)
+
+// BenchmarkRepository 共通のベンチマーク（repositorybench）を実行する
+func BenchmarkRepository(b *testing.B) {
+	repositorybench.Run(b, func(b *testing.B) model.UserRepository {
+		return NewUserRepository()
+	})
+}
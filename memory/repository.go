@@ -0,0 +1,448 @@
+// Package memory データベースを使わずにメモリ上でユーザーを管理するリポジトリ
+//
+// 単体テストやデータベースのない環境での開発に使う。
+// MySQLのusersテーブルと同じく、IDの自動採番、メールアドレスの一意制約、作成日時と更新日時の管理を行う。
+package memory
+
+import (
+	"context"
+	"go_sql_library/model"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// table usersテーブルに相当する行とインデックス
+type table struct {
+	// rows IDをキーにした行
+	rows map[int]*model.User
+
+	// ids 行のIDの昇順（採番は常に増えるため、末尾に追加すれば順序が保たれる）
+	ids []int
+
+	// emails メールアドレスの一意制約（emailKeyで正規化したメールアドレスからIDを引く）
+	emails map[string]int
+
+	// nextID 次に採番するID（AUTO_INCREMENTと同じく削除しても再利用しない）
+	nextID int
+}
+
+// newTable 空のテーブルを作る
+func newTable() *table {
+	return &table{
+		rows:   map[int]*model.User{},
+		emails: map[string]int{},
+		nextID: 1,
+	}
+}
+
+// clone トランザクション用にテーブルを複製する
+func (t *table) clone() *table {
+	c := &table{
+		rows:   make(map[int]*model.User, len(t.rows)),
+		ids:    append([]int(nil), t.ids...),
+		emails: make(map[string]int, len(t.emails)),
+		nextID: t.nextID,
+	}
+	for id, u := range t.rows {
+		row := *u
+		c.rows[id] = &row
+	}
+	for key, id := range t.emails {
+		c.emails[key] = id
+	}
+	return c
+}
+
+// emailKey 一意制約の判定に使うメールアドレス
+// テーブルの照合順序（utf8mb4_unicode_ci）と同じく大文字と小文字を区別しない
+func emailKey(email string) string {
+	return strings.ToLower(email)
+}
+
+// database 同じNewUserRepositoryから作ったリポジトリで共有する状態
+type database struct {
+	mu   sync.RWMutex
+	data *table
+}
+
+// UserRepository メモリ上のユーザーリポジトリ
+// 複数のゴルーチンから同時に使える
+type UserRepository struct {
+	db *database
+
+	// tx トランザクション内で操作するテーブルの複製（WithTx内のリポジトリのみ）
+	// WithTxがdb.muのロックを取ったまま使うため、ロックせずに読み書きする
+	tx *table
+
+	// now 現在時刻（テストで差し替える）
+	now func() time.Time
+}
+
+// NewUserRepository リポジトリの初期化
+func NewUserRepository() *UserRepository {
+	return &UserRepository{db: &database{data: newTable()}, now: now}
+}
+
+// now MySQLのTIMESTAMPと同じく秒単位に切り捨てたUTCの現在時刻
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// read テーブルを読み取り専用で使う
+func (r *UserRepository) read(ctx context.Context, fn func(t *table) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+	return fn(r.db.data)
+}
+
+// write テーブルを書き込み用に使う
+// fnがエラーを返す場合はテーブルを変更しないこと
+func (r *UserRepository) write(ctx context.Context, fn func(t *table) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+	return fn(r.db.data)
+}
+
+// live 削除されていないユーザーの行を取得
+func (t *table) live(id int) (*model.User, error) {
+	u, ok := t.rows[id]
+	if !ok || u.DeletedAt != nil {
+		return nil, model.ErrNotFound
+	}
+	return u, nil
+}
+
+// checkVersion 削除されていないユーザーの行を、バージョンが一致する場合のみ取得
+// ユーザーが存在しなければErrNotFound、バージョンが一致しなければErrVersionConflictを返す
+func (t *table) checkVersion(id, version int) (*model.User, error) {
+	u, err := t.live(id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Version != version {
+		return nil, model.ErrVersionConflict
+	}
+	return u, nil
+}
+
+// checkEmail emailを行idのメールアドレスとして使えるか確認する
+// 論理削除済みのユーザーも含め、他のユーザーが使っていればErrDuplicateEmailを返す
+func (t *table) checkEmail(id int, email string) error {
+	if other, ok := t.emails[emailKey(email)]; ok && other != id {
+		return model.ErrDuplicateEmail
+	}
+	return nil
+}
+
+// insert 行を追加する（メールアドレスの重複は呼び出し側で確認しておく）
+func (t *table) insert(name, email string, now time.Time) *model.User {
+	u := &model.User{
+		ID:        t.nextID,
+		Name:      name,
+		Email:     email,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	t.nextID++
+	t.rows[u.ID] = u
+	t.ids = append(t.ids, u.ID)
+	t.emails[emailKey(email)] = u.ID
+	return u
+}
+
+// setEmail 行のメールアドレスを変更し、一意制約のインデックスを付け替える
+func (t *table) setEmail(u *model.User, email string) {
+	delete(t.emails, emailKey(u.Email))
+	u.Email = email
+	t.emails[emailKey(email)] = u.ID
+}
+
+// copyUser 行を呼び出し側に返すためにコピーする
+func copyUser(u *model.User) *model.User {
+	user := *u
+	return &user
+}
+
+// GetAll 削除されていない全ユーザーを取得
+func (r *UserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	users := []model.User{}
+	err := r.read(ctx, func(t *table) error {
+		for _, id := range t.ids {
+			if u := t.rows[id]; u.DeletedAt == nil {
+				users = append(users, *u)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// List filterに一致するユーザーをIDの昇順でpage.AfterIDより後ろから最大page.Limit件取得
+// 名前とメールアドレスの比較はテーブルの照合順序と同じく大文字と小文字を区別しない
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter, page model.Page) ([]model.User, error) {
+	nameContains := strings.ToLower(filter.NameContains)
+	emailSuffix := "@" + strings.ToLower(filter.EmailDomain)
+
+	users := []model.User{}
+	err := r.read(ctx, func(t *table) error {
+		start := sort.SearchInts(t.ids, page.AfterID+1)
+		for _, id := range t.ids[start:] {
+			if len(users) >= page.Limit {
+				break
+			}
+
+			u := t.rows[id]
+			switch {
+			case u.DeletedAt != nil && !filter.IncludeDeleted:
+			case filter.NameContains != "" && !strings.Contains(strings.ToLower(u.Name), nameContains):
+			case filter.EmailDomain != "" && !strings.HasSuffix(strings.ToLower(u.Email), emailSuffix):
+			case !filter.CreatedFrom.IsZero() && u.CreatedAt.Before(filter.CreatedFrom):
+			case !filter.CreatedTo.IsZero() && !u.CreatedAt.Before(filter.CreatedTo):
+			default:
+				users = append(users, *u)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetByID IDで削除されていないユーザーを取得
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	var user *model.User
+	err := r.read(ctx, func(t *table) error {
+		u, err := t.live(id)
+		if err != nil {
+			return err
+		}
+		user = copyUser(u)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Create 新規ユーザーを作成
+func (r *UserRepository) Create(ctx context.Context, name, email string) (*model.User, error) {
+	var user *model.User
+	err := r.write(ctx, func(t *table) error {
+		if err := t.checkEmail(0, email); err != nil {
+			return err
+		}
+		user = copyUser(t.insert(name, email, r.now()))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateMany 複数のユーザーをまとめて作成
+// 先にすべてのメールアドレスを確認し、1件でも重複があれば1件も作成しない
+func (r *UserRepository) CreateMany(ctx context.Context, users []model.NewUser) ([]int, error) {
+	ids := make([]int, 0, len(users))
+	err := r.write(ctx, func(t *table) error {
+		seen := make(map[string]bool, len(users))
+		for _, u := range users {
+			key := emailKey(u.Email)
+			if seen[key] {
+				return model.ErrDuplicateEmail
+			}
+			if err := t.checkEmail(0, u.Email); err != nil {
+				return err
+			}
+			seen[key] = true
+		}
+
+		now := r.now()
+		for _, u := range users {
+			ids = append(ids, t.insert(u.Name, u.Email, now).ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Upsert メールアドレスをキーにユーザーを作成または名前を更新
+// 既存のユーザーはON DUPLICATE KEY UPDATEと同じくバージョンを増やし、論理削除済みであれば復元する
+func (r *UserRepository) Upsert(ctx context.Context, name, email string) (*model.User, bool, error) {
+	var user *model.User
+	var inserted bool
+	err := r.write(ctx, func(t *table) error {
+		id, ok := t.emails[emailKey(email)]
+		if !ok {
+			user = copyUser(t.insert(name, email, r.now()))
+			inserted = true
+			return nil
+		}
+
+		u := t.rows[id]
+		u.Name = name
+		u.DeletedAt = nil
+		u.Version++
+		u.UpdatedAt = r.now()
+		user = copyUser(u)
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return user, inserted, nil
+}
+
+// Update 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+func (r *UserRepository) Update(ctx context.Context, id int, name, email string, version int) error {
+	return r.write(ctx, func(t *table) error {
+		u, err := t.checkVersion(id, version)
+		if err != nil {
+			return err
+		}
+		if err := t.checkEmail(id, email); err != nil {
+			return err
+		}
+
+		u.Name = name
+		t.setEmail(u, email)
+		u.Version++
+		u.UpdatedAt = r.now()
+		return nil
+	})
+}
+
+// Patch 指定したフィールドだけをバージョンが一致する場合のみ更新
+func (r *UserRepository) Patch(ctx context.Context, id int, patch model.UserPatch, version int) error {
+	return r.write(ctx, func(t *table) error {
+		u, err := t.checkVersion(id, version)
+		if err != nil {
+			return err
+		}
+		if patch.Email != nil {
+			if err := t.checkEmail(id, *patch.Email); err != nil {
+				return err
+			}
+		}
+
+		if patch.Name != nil {
+			u.Name = *patch.Name
+		}
+		if patch.Email != nil {
+			t.setEmail(u, *patch.Email)
+		}
+		u.Version++
+		u.UpdatedAt = r.now()
+		return nil
+	})
+}
+
+// Delete ユーザーをバージョンが一致する場合のみ論理削除
+// SQLの実装と同じく、論理削除ではバージョンを増やさない
+func (r *UserRepository) Delete(ctx context.Context, id int, version int) error {
+	return r.write(ctx, func(t *table) error {
+		u, err := t.checkVersion(id, version)
+		if err != nil {
+			return err
+		}
+
+		now := r.now()
+		u.DeletedAt = &now
+		u.UpdatedAt = now
+		return nil
+	})
+}
+
+// Restore 論理削除したユーザーを復元
+func (r *UserRepository) Restore(ctx context.Context, id int) error {
+	return r.write(ctx, func(t *table) error {
+		u, ok := t.rows[id]
+		if !ok || u.DeletedAt == nil {
+			return model.ErrNotFound
+		}
+
+		u.DeletedAt = nil
+		u.Version++
+		u.UpdatedAt = r.now()
+		return nil
+	})
+}
+
+// Purge olderThanより前に論理削除したユーザーを物理削除し、削除件数を返す
+func (r *UserRepository) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	var purged int64
+	err := r.write(ctx, func(t *table) error {
+		ids := t.ids[:0]
+		for _, id := range t.ids {
+			u := t.rows[id]
+			if u.DeletedAt == nil || !u.DeletedAt.Before(olderThan) {
+				ids = append(ids, id)
+				continue
+			}
+			delete(t.rows, id)
+			delete(t.emails, emailKey(u.Email))
+			purged++
+		}
+		t.ids = ids
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+// WithTx fnをトランザクション内で実行する
+// テーブルの複製に対して操作し、fnがnilを返した場合のみ複製で置き換える（エラーやパニックでは破棄する）
+// 実行中は書き込みのロックを取るため、トランザクションは1つずつ順に実行される
+// fnの中では渡されたリポジトリだけを使うこと（元のリポジトリを使うとロックを待ち続ける）
+func (r *UserRepository) WithTx(ctx context.Context, fn func(repo model.UserRepository) error) error {
+	// 既にトランザクション内であれば同じトランザクションに参加する
+	if r.tx != nil {
+		return fn(r)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	tx := r.db.data.clone()
+	if err := fn(&UserRepository{db: r.db, tx: tx, now: r.now}); err != nil {
+		return err
+	}
+	r.db.data = tx
+	return nil
+}
+
+// Close 何もしない（閉じる接続がないため）
+func (r *UserRepository) Close() error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"go_sql_library/model"
+	"go_sql_library/repositorytest"
+	"testing"
+	"time"
+)
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+	repositorytest.RunConformance(t, func(t *testing.T) model.UserRepository {
+		return NewUserRepository()
+	})
+}
+
+// TestUserRepository_Purge 削除日時を過去にするため、現在時刻を差し替えて論理削除する
+func TestUserRepository_Purge(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+
+	created, err := repo.Create(ctx, "物理削除用", "test_purge_memory@example.com")
+	if err != nil {
+		t.Fatalf("Create エラー: %v", err)
+	}
+	kept, err := repo.Create(ctx, "物理削除対象外", "test_purge_memory_kept@example.com")
+	if err != nil {
+		t.Fatalf("Create エラー: %v", err)
+	}
+
+	repo.now = func() time.Time { return time.Now().Add(-48 * time.Hour) }
+	if err := repo.Delete(ctx, created.ID, created.Version); err != nil {
+		t.Fatalf("Delete エラー: %v", err)
+	}
+	repo.now = now
+	if err := repo.Delete(ctx, kept.ID, kept.Version); err != nil {
+		t.Fatalf("Delete エラー: %v", err)
+	}
+
+	purged, err := repo.Purge(ctx, time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Purge エラー: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("期待する削除件数: 1, 実際: %d", purged)
+	}
+
+	// 物理削除したユーザーは復元できず、メールアドレスは再び使える
+	if err := repo.Restore(ctx, created.ID); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("期待するエラー: model.ErrNotFound, 実際: %v", err)
+	}
+	if _, err := repo.Create(ctx, "再作成", created.Email); err != nil {
+		t.Errorf("物理削除したメールアドレスで作成できません: %v", err)
+	}
+
+	// 削除日時が新しいユーザーは残っている
+	if err := repo.Restore(ctx, kept.ID); err != nil {
+		t.Errorf("Restore エラー: %v", err)
+	}
+}
+
+// TestUserRepository_EmailCaseInsensitive MySQLの照合順序と同じく、大文字と小文字だけが違うメールアドレスは重複になる
+func TestUserRepository_EmailCaseInsensitive(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+
+	if _, err := repo.Create(ctx, "小文字", "test_case@example.com"); err != nil {
+		t.Fatalf("Create エラー: %v", err)
+	}
+	_, err := repo.Create(ctx, "大文字", "TEST_CASE@example.com")
+	if !errors.Is(err, model.ErrDuplicateEmail) {
+		t.Errorf("期待するエラー: model.ErrDuplicateEmail, 実際: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+// Package middleware UserRepositoryに横断的な処理を追加するミドルウェア（model.Middleware）
+//
+// どのミドルウェアもAroundで作り、UserRepositoryの各メソッドの呼び出しを1つの関数で包む。
+// WithTxに渡す関数が受け取るリポジトリも同じミドルウェアで包むため、トランザクション内の操作にも処理が追加される。
+package middleware
+
+import (
+	"context"
+	"go_sql_library/model"
+	"log"
+	"time"
+)
+
+// AroundFunc メソッドの呼び出しを包む関数
+// methodはメソッド名（例: GetByID）、callは包んだリポジトリのメソッドを呼び出し、そのエラーを返す
+// callを呼ばずに返すと、包んだリポジトリのメソッドは呼び出されない
+type AroundFunc func(ctx context.Context, method string, call func() error) error
+
+// Around すべてのメソッドの呼び出しをaroundで包むミドルウェアを返す
+func Around(around AroundFunc) model.Middleware {
+	return func(next model.UserRepository) model.UserRepository {
+		return &repository{next: next, around: around}
+	}
+}
+
+// Logging メソッドの呼び出しと結果（エラー）をloggerに出力するミドルウェアを返す
+func Logging(logger *log.Logger) model.Middleware {
+	return Around(func(ctx context.Context, method string, call func() error) error {
+		err := call()
+		if err != nil {
+			logger.Printf("UserRepository.%s: %v", method, err)
+		} else {
+			logger.Printf("UserRepository.%s: ok", method)
+		}
+		return err
+	})
+}
+
+// Timing メソッドの実行時間を計測し、observeに渡すミドルウェアを返す
+// エラーを返した呼び出しも計測する
+func Timing(observe func(method string, elapsed time.Duration)) model.Middleware {
+	return Around(func(ctx context.Context, method string, call func() error) error {
+		start := time.Now()
+		err := call()
+		observe(method, time.Since(start))
+		return err
+	})
+}
+
+// repository nextの各メソッドの呼び出しをaroundで包むリポジトリ
+type repository struct {
+	next   model.UserRepository
+	around AroundFunc
+}
+
+// GetAll nextのGetAllをaroundで包んで呼び出す
+func (r *repository) GetAll(ctx context.Context) ([]model.User, error) {
+	var users []model.User
+	err := r.around(ctx, "GetAll", func() error {
+		var err error
+		users, err = r.next.GetAll(ctx)
+		return err
+	})
+	return users, err
+}
+
+// List nextのListをaroundで包んで呼び出す
+func (r *repository) List(ctx context.Context, filter model.UserFilter, page model.Page) ([]model.User, error) {
+	var users []model.User
+	err := r.around(ctx, "List", func() error {
+		var err error
+		users, err = r.next.List(ctx, filter, page)
+		return err
+	})
+	return users, err
+}
+
+// GetByID nextのGetByIDをaroundで包んで呼び出す
+func (r *repository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	var user *model.User
+	err := r.around(ctx, "GetByID", func() error {
+		var err error
+		user, err = r.next.GetByID(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+// Create nextのCreateをaroundで包んで呼び出す
+func (r *repository) Create(ctx context.Context, name, email string) (*model.User, error) {
+	var user *model.User
+	err := r.around(ctx, "Create", func() error {
+		var err error
+		user, err = r.next.Create(ctx, name, email)
+		return err
+	})
+	return user, err
+}
+
+// CreateMany nextのCreateManyをaroundで包んで呼び出す
+func (r *repository) CreateMany(ctx context.Context, users []model.NewUser) ([]int, error) {
+	var ids []int
+	err := r.around(ctx, "CreateMany", func() error {
+		var err error
+		ids, err = r.next.CreateMany(ctx, users)
+		return err
+	})
+	return ids, err
+}
+
+// Upsert nextのUpsertをaroundで包んで呼び出す
+func (r *repository) Upsert(ctx context.Context, name, email string) (*model.User, bool, error) {
+	var user *model.User
+	var inserted bool
+	err := r.around(ctx, "Upsert", func() error {
+		var err error
+		user, inserted, err = r.next.Upsert(ctx, name, email)
+		return err
+	})
+	return user, inserted, err
+}
+
+// Update nextのUpdateをaroundで包んで呼び出す
+func (r *repository) Update(ctx context.Context, id int, name, email string, version int) error {
+	return r.around(ctx, "Update", func() error {
+		return r.next.Update(ctx, id, name, email, version)
+	})
+}
+
+// Patch nextのPatchをaroundで包んで呼び出す
+func (r *repository) Patch(ctx context.Context, id int, patch model.UserPatch, version int) error {
+	return r.around(ctx, "Patch", func() error {
+		return r.next.Patch(ctx, id, patch, version)
+	})
+}
+
+// Delete nextのDeleteをaroundで包んで呼び出す
+func (r *repository) Delete(ctx context.Context, id int, version int) error {
+	return r.around(ctx, "Delete", func() error {
+		return r.next.Delete(ctx, id, version)
+	})
+}
+
+// Restore nextのRestoreをaroundで包んで呼び出す
+func (r *repository) Restore(ctx context.Context, id int) error {
+	return r.around(ctx, "Restore", func() error {
+		return r.next.Restore(ctx, id)
+	})
+}
+
+// Purge nextのPurgeをaroundで包んで呼び出す
+func (r *repository) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	var purged int64
+	err := r.around(ctx, "Purge", func() error {
+		var err error
+		purged, err = r.next.Purge(ctx, olderThan)
+		return err
+	})
+	return purged, err
+}
+
+// WithTx トランザクション全体をaroundで包み、fnに渡すトランザクション用のリポジトリも同じaroundで包む
+func (r *repository) WithTx(ctx context.Context, fn func(repo model.UserRepository) error) error {
+	return r.around(ctx, "WithTx", func() error {
+		return r.next.WithTx(ctx, func(tx model.UserRepository) error {
+			return fn(&repository{next: tx, around: r.around})
+		})
+	})
+}
+
+// Close 包んだリポジトリのCloseを呼び出す（データベース接続はリポジトリを作った実装が閉じる）
+func (r *repository) Close() error {
+	return r.around(context.Background(), "Close", r.next.Close)
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go_sql_library/memory"
+	"go_sql_library/model"
+	"go_sql_library/repositorytest"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recorder 呼び出されたメソッドをnameを付けて記録するミドルウェアを返す
+func recorder(name string, calls *[]string) model.Middleware {
+	return Around(func(ctx context.Context, method string, call func() error) error {
+		*calls = append(*calls, name+"."+method)
+		return call()
+	})
+}
+
+// closeCounter Closeの呼び出し回数を数えるリポジトリ
+type closeCounter struct {
+	model.UserRepository
+	closed int
+	err    error
+}
+
+func (r *closeCounter) Close() error {
+	r.closed++
+	return r.err
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+	// ミドルウェアで包んでも、リポジトリの振る舞いは変わらない
+	repositorytest.RunConformance(t, func(t *testing.T) model.UserRepository {
+		return model.Chain(memory.NewUserRepository(),
+			Logging(log.New(io.Discard, "", 0)),
+			Timing(func(string, time.Duration) {}),
+		)
+	})
+}
+
+func TestChain_Order(t *testing.T) {
+	var calls []string
+	repo := model.Chain(memory.NewUserRepository(), recorder("outer", &calls), recorder("inner", &calls))
+
+	if _, err := repo.GetAll(context.Background()); err != nil {
+		t.Fatalf("GetAll エラー: %v", err)
+	}
+	want := []string{"outer.GetAll", "inner.GetAll"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("期待する呼び出し順: %v, 実際: %v", want, calls)
+	}
+}
+
+func TestWithTx_WrapsTxRepository(t *testing.T) {
+	var calls []string
+	repo := model.Chain(memory.NewUserRepository(), recorder("outer", &calls), recorder("inner", &calls))
+
+	err := repo.WithTx(context.Background(), func(tx model.UserRepository) error {
+		_, err := tx.Create(context.Background(), "トランザクション", "test_middleware_tx@example.com")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx エラー: %v", err)
+	}
+	// トランザクション内の操作も同じ順でミドルウェアを通る
+	want := []string{"outer.WithTx", "inner.WithTx", "outer.Create", "inner.Create"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("期待する呼び出し順: %v, 実際: %v", want, calls)
+	}
+}
+
+func TestClose_PassesThrough(t *testing.T) {
+	errClose := errors.New("close failed")
+	backend := &closeCounter{UserRepository: memory.NewUserRepository(), err: errClose}
+	var calls []string
+	repo := model.Chain(backend, recorder("outer", &calls), Logging(log.New(io.Discard, "", 0)), recorder("inner", &calls))
+
+	if err := repo.Close(); !errors.Is(err, errClose) {
+		t.Errorf("期待するエラー: %v, 実際: %v", errClose, err)
+	}
+	if backend.closed != 1 {
+		t.Errorf("期待するCloseの呼び出し回数: 1, 実際: %d", backend.closed)
+	}
+	want := []string{"outer.Close", "inner.Close"}
+	if !slices.Equal(calls, want) {
+		t.Errorf("期待する呼び出し順: %v, 実際: %v", want, calls)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	var buf bytes.Buffer
+	repo := model.Chain(memory.NewUserRepository(), Logging(log.New(&buf, "", 0)))
+
+	if _, err := repo.GetByID(context.Background(), 999); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("期待するエラー: ErrNotFound, 実際: %v", err)
+	}
+	if _, err := repo.GetAll(context.Background()); err != nil {
+		t.Fatalf("GetAll エラー: %v", err)
+	}
+
+	want := "UserRepository.GetByID: user not found\nUserRepository.GetAll: ok\n"
+	if got := buf.String(); got != want {
+		t.Errorf("期待するログ: %q, 実際: %q", want, got)
+	}
+}
+
+func TestTiming(t *testing.T) {
+	var methods []string
+	repo := model.Chain(memory.NewUserRepository(), Timing(func(method string, elapsed time.Duration) {
+		if elapsed < 0 {
+			t.Errorf("%s: 実行時間が負の値です: %v", method, elapsed)
+		}
+		methods = append(methods, method)
+	}))
+
+	// エラーを返した呼び出しも計測する
+	repo.GetByID(context.Background(), 999)
+	repo.Close()
+
+	if got := strings.Join(methods, ","); got != "GetByID,Close" {
+		t.Errorf("期待する計測対象: GetByID,Close, 実際: %s", got)
+	}
+}
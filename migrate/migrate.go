@@ -0,0 +1,277 @@
+// Package migrate バージョン付きのマイグレーションでテーブル定義を管理する
+//
+// マイグレーションはmigrations/<ドライバ名>/に「0001_create_users.up.sql」と「0001_create_users.down.sql」の
+// 組で置き、バイナリに埋め込む。適用済みのバージョンはschema_migrationsテーブルに記録する。
+// 1つのマイグレーションはトランザクション内で実行するが、MySQLではDDLが暗黙的にコミットされるため、
+// 途中で失敗した場合は実行済みの文を手動で戻す必要がある。
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"go_sql_library/dbdriver"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// createTableQuery 適用済みのバージョンを記録するテーブル（MySQL、SQLite、PostgreSQLで共通の定義）
+const createTableQuery = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`
+
+// Migration 1つのバージョンのマイグレーション
+type Migration struct {
+	Version int
+	Name    string
+	Up      string // 適用するSQL
+	Down    string // 戻すSQL
+}
+
+// Status マイグレーションと適用状況
+type Status struct {
+	Migration
+	AppliedAt *time.Time // 未適用の場合はnil
+}
+
+// Load driverのマイグレーションをバージョン順に読み込む
+func Load(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("マイグレーションのない接続先: %s", driver)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		version, name, direction, ok := parseFileName(entry.Name())
+		if !ok {
+			return nil, fmt.Errorf("マイグレーションのファイル名が不正: %s", entry.Name())
+		}
+		script, err := fs.ReadFile(migrationsFS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("バージョン%dのマイグレーションの名前が一致しない: %s, %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("バージョン%dのマイグレーションにupとdownの両方がない", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return a.Version - b.Version })
+	return migrations, nil
+}
+
+// Up 未適用のマイグレーションをバージョン順にすべて適用し、適用したマイグレーションを返す
+// 以前のinit.sqlで作成したデータベース（usersテーブルがあり、適用済みのバージョンがない）では、
+// 最初のマイグレーション（usersテーブルの作成）を適用済みとして記録し、既存のデータを残したまま続きを適用する
+func Up(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	driver := dbdriver.Name(db)
+	migrations, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 && tableExists(ctx, db, "users") {
+		first := migrations[0]
+		query := dbdriver.Rebind(driver, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)")
+		if _, err := db.ExecContext(ctx, query, first.Version, first.Name); err != nil {
+			return nil, fmt.Errorf("既存のデータベースの記録エラー: %w", err)
+		}
+		applied[first.Version] = time.Now()
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := run(ctx, db, driver, m.Up, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name)
+		if err != nil {
+			return done, fmt.Errorf("バージョン%d（%s）の適用エラー: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// Down 最後に適用したマイグレーションを1つ戻し、戻したマイグレーションを返す
+// 適用済みのマイグレーションがない場合はnilを返す
+func Down(ctx context.Context, db *sql.DB) (*Migration, error) {
+	driver := dbdriver.Name(db)
+	migrations, err := Load(driver)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		return nil, nil
+	}
+
+	latest := slices.Max(slices.Collect(maps.Keys(applied)))
+	i := slices.IndexFunc(migrations, func(m Migration) bool { return m.Version == latest })
+	if i < 0 {
+		// 新しいバイナリで適用したマイグレーションは、そのバイナリでなければ戻せない
+		return nil, fmt.Errorf("適用済みのバージョン%dのマイグレーションがない", latest)
+	}
+
+	m := migrations[i]
+	if err := run(ctx, db, driver, m.Down, "DELETE FROM schema_migrations WHERE version = ?", m.Version); err != nil {
+		return nil, fmt.Errorf("バージョン%d（%s）を戻す際のエラー: %w", m.Version, m.Name, err)
+	}
+	return &m, nil
+}
+
+// List すべてのマイグレーションと適用状況をバージョン順に返す
+func List(ctx context.Context, db *sql.DB) ([]Status, error) {
+	migrations, err := Load(dbdriver.Name(db))
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if appliedAt, ok := applied[m.Version]; ok {
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// appliedVersions schema_migrationsテーブルがなければ作成し、適用済みのバージョンと適用日時を返す
+func appliedVersions(ctx context.Context, db *sql.DB) (map[int]time.Time, error) {
+	if _, err := db.ExecContext(ctx, createTableQuery); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// tableExists tableがあるかどうかを返す（information_schemaのないSQLiteでも使えるよう、テーブルを参照して調べる）
+func tableExists(ctx context.Context, db *sql.DB, table string) bool {
+	err := db.QueryRowContext(ctx, "SELECT 1 FROM "+table+" WHERE 1 = 0").Scan(new(int))
+	return errors.Is(err, sql.ErrNoRows)
+}
+
+// run マイグレーションのSQLと、schema_migrationsを更新するrecordを同じトランザクションで実行する
+func run(ctx context.Context, db *sql.DB, driver, script, record string, args ...any) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements(driver, script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, dbdriver.Rebind(driver, record), args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// statements マイグレーションのSQLを1回のExecで実行する単位に分ける
+// go-sql-driver/mysqlは1回のExecで1つの文しか実行できないため、MySQLのみ;で分ける
+// （MySQLのマイグレーションでは文字列リテラルに;を含めないこと）
+// SQLiteとPostgreSQLはトリガーや関数の本体に;を含むため、スクリプト全体をそのまま実行する
+func statements(driver, script string) []string {
+	if driver != dbdriver.MySQL {
+		return []string{script}
+	}
+
+	var stmts []string
+	for _, stmt := range strings.Split(script, ";") {
+		var lines []string
+		for _, line := range strings.Split(stmt, "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				lines = append(lines, line)
+			}
+		}
+		if s := strings.TrimSpace(strings.Join(lines, "\n")); s != "" {
+			stmts = append(stmts, s)
+		}
+	}
+	return stmts
+}
+
+// parseFileName 「0001_create_users.up.sql」をバージョン、名前、向き（upまたはdown）に分ける
+func parseFileName(file string) (version int, name, direction string, ok bool) {
+	base, ok := strings.CutSuffix(file, ".sql")
+	if !ok {
+		return 0, "", "", false
+	}
+	i := strings.LastIndex(base, ".")
+	if i < 0 {
+		return 0, "", "", false
+	}
+	base, direction = base[:i], base[i+1:]
+	if direction != "up" && direction != "down" {
+		return 0, "", "", false
+	}
+
+	v, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", false
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || version <= 0 {
+		return 0, "", "", false
+	}
+	return version, name, direction, true
+}
@@ -0,0 +1,152 @@
+package migrate_test
+
+import (
+	"context"
+	"go_sql_library/dbdriver"
+	"go_sql_library/dbtest"
+	"go_sql_library/migrate"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	for _, driver := range []string{dbdriver.MySQL, dbdriver.SQLite, dbdriver.Postgres} {
+		migrations, err := migrate.Load(driver)
+		if err != nil {
+			t.Fatalf("%s: Load エラー: %v", driver, err)
+		}
+		if len(migrations) == 0 {
+			t.Fatalf("%s: マイグレーションがありません", driver)
+		}
+		// バージョンは1から欠番なく並ぶ
+		for i, m := range migrations {
+			if m.Version != i+1 {
+				t.Errorf("%s: %d番目の期待するバージョン: %d, 実際: %d", driver, i, i+1, m.Version)
+			}
+			if m.Up == "" || m.Down == "" {
+				t.Errorf("%s: バージョン%dのupまたはdownが空です", driver, m.Version)
+			}
+		}
+	}
+
+	if _, err := migrate.Load("oracle"); err == nil {
+		t.Error("マイグレーションのない接続先でエラーが返されませんでした")
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	// dbtestはすべてのマイグレーションを適用済みのデータベースを返す
+	db := dbtest.Open(t)
+
+	statuses, err := migrate.List(ctx, db)
+	if err != nil {
+		t.Fatalf("List エラー: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("バージョン%dが適用されていません", s.Version)
+		}
+	}
+
+	// 適用済みのマイグレーションは再度適用しない
+	applied, err := migrate.Up(ctx, db)
+	if err != nil {
+		t.Fatalf("Up エラー: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("期待する適用件数: 0, 実際: %d", len(applied))
+	}
+
+	// 新しいバージョンから順にすべて戻す
+	for i := len(statuses) - 1; i >= 0; i-- {
+		m, err := migrate.Down(ctx, db)
+		if err != nil {
+			t.Fatalf("Down エラー: %v", err)
+		}
+		if m == nil || m.Version != statuses[i].Version {
+			t.Fatalf("期待するバージョン: %d, 実際: %v", statuses[i].Version, m)
+		}
+	}
+	if m, err := migrate.Down(ctx, db); err != nil || m != nil {
+		t.Fatalf("適用済みのマイグレーションがない場合の期待する結果: nil, nil, 実際: %v, %v", m, err)
+	}
+	if _, err := db.ExecContext(ctx, "SELECT 1 FROM users"); err == nil {
+		t.Error("すべて戻した後もusersテーブルが残っています")
+	}
+
+	statuses, err = migrate.List(ctx, db)
+	if err != nil {
+		t.Fatalf("List エラー: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			t.Errorf("バージョン%dが適用済みのままです", s.Version)
+		}
+	}
+
+	// 戻した後も同じ手順で作り直せる
+	applied, err = migrate.Up(ctx, db)
+	if err != nil {
+		t.Fatalf("Up エラー: %v", err)
+	}
+	if len(applied) != len(statuses) {
+		t.Errorf("期待する適用件数: %d, 実際: %d", len(statuses), len(applied))
+	}
+
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("usersテーブルの確認エラー: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("期待するサンプルデータの件数: 3, 実際: %d", count)
+	}
+}
+
+func TestUp_ExistingDatabase(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := dbtest.Open(t)
+
+	// 以前のinit.sqlで作成したデータベースと同じく、usersテーブルだけがあり適用済みのバージョンがない状態にする
+	migrations, err := migrate.Load(dbdriver.Name(db))
+	if err != nil {
+		t.Fatalf("Load エラー: %v", err)
+	}
+	for range migrations[1:] {
+		if _, err := migrate.Down(ctx, db); err != nil {
+			t.Fatalf("Down エラー: %v", err)
+		}
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		t.Fatalf("schema_migrationsの削除エラー: %v", err)
+	}
+
+	// 最初のマイグレーションは適用済みとして記録し、続きだけを適用する
+	applied, err := migrate.Up(ctx, db)
+	if err != nil {
+		t.Fatalf("Up エラー: %v", err)
+	}
+	if len(applied) != len(migrations)-1 {
+		t.Errorf("期待する適用件数: %d, 実際: %d", len(migrations)-1, len(applied))
+	}
+
+	statuses, err := migrate.List(ctx, db)
+	if err != nil {
+		t.Fatalf("List エラー: %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("バージョン%dが適用されていません", s.Version)
+		}
+	}
+
+	// 既存のデータは残る
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("usersテーブルの確認エラー: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("期待するサンプルデータの件数: 3, 実際: %d", count)
+	}
+}
@@ -0,0 +1 @@
+CREATE INDEX idx_email ON users(email);
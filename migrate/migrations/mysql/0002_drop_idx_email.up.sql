@@ -0,0 +1,2 @@
+-- emailにはUNIQUE制約のインデックスがあるため、同じ列のidx_emailは不要
+DROP INDEX idx_email ON users;
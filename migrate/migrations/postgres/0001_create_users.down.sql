@@ -0,0 +1,3 @@
+-- citextは他のテーブルでも使える拡張機能のため削除しない
+DROP TABLE users;
+DROP FUNCTION set_updated_at();
@@ -0,0 +1,35 @@
+-- usersテーブルの作成（MySQLのusersテーブルに合わせる）
+
+-- MySQLの照合順序（utf8mb4_unicode_ci）と同じく、メールアドレスの大文字と小文字を区別しない
+CREATE EXTENSION IF NOT EXISTS citext;
+
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    email CITEXT NOT NULL UNIQUE CHECK (char_length(email) <= 100),
+    version INT NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ NULL DEFAULT NULL
+);
+
+-- サンプルデータの挿入
+INSERT INTO users (name, email) VALUES
+    ('山田太郎', 'yamada@example.com'),
+    ('佐藤花子', 'sato@example.com'),
+    ('鈴木一郎', 'suzuki@example.com');
+
+-- PostgreSQLにはON UPDATE CURRENT_TIMESTAMPがないため、トリガーで更新日時を設定する
+-- 更新日時を明示的に変更した場合（GORMやent）はそのままにする
+CREATE FUNCTION set_updated_at() RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.updated_at IS NOT DISTINCT FROM OLD.updated_at THEN
+        NEW.updated_at = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_updated_at
+BEFORE UPDATE ON users
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,2 @@
+DROP TRIGGER users_updated_at;
+DROP TABLE users;
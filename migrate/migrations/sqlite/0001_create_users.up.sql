@@ -0,0 +1,27 @@
+-- usersテーブルの作成（MySQLのusersテーブルに合わせる）
+CREATE TABLE users (
+    -- AUTOINCREMENTを付けて、MySQLと同じく削除したIDを再利用しない
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(100) NOT NULL,
+    -- MySQLの照合順序（utf8mb4_unicode_ci）と同じく大文字と小文字を区別しない
+    email VARCHAR(100) NOT NULL UNIQUE COLLATE NOCASE,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL DEFAULT NULL
+);
+
+-- サンプルデータの挿入
+INSERT INTO users (name, email) VALUES
+    ('山田太郎', 'yamada@example.com'),
+    ('佐藤花子', 'sato@example.com'),
+    ('鈴木一郎', 'suzuki@example.com');
+
+-- SQLiteにはON UPDATE CURRENT_TIMESTAMPがないため、トリガーで更新日時を設定する
+-- 更新日時を明示的に変更した場合（GORMやent）はそのままにする
+CREATE TRIGGER users_updated_at
+AFTER UPDATE ON users
+FOR EACH ROW WHEN NEW.updated_at IS OLD.updated_at
+BEGIN
+    UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+END;
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// リポジトリが返すドメインエラー
+// 各実装はライブラリやドライバ固有のエラーをこれらに変換して返す
+var (
+	// ErrNotFound 対象のユーザーが存在しない
+	ErrNotFound = errors.New("user not found")
+
+	// ErrConflict 他のデータと競合して処理できない
+	ErrConflict = errors.New("conflict")
+
+	// ErrDuplicateEmail メールアドレスが既に使われている（ErrConflictの一種）
+	ErrDuplicateEmail = fmt.Errorf("%w: duplicate email", ErrConflict)
+
+	// ErrVersionConflict 指定したバージョンが現在のバージョンと一致しない（ErrConflictの一種）
+	ErrVersionConflict = fmt.Errorf("%w: version mismatch", ErrConflict)
+)
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// LikeEscapeChar LIKE句で使うエスケープ文字
+// バックスラッシュは文字列リテラルでの扱いがDBごとに異なるため使わない
+const LikeEscapeChar = "!"
+
+// likeEscaper LIKEのワイルドカードとエスケープ文字自体をエスケープする
+var likeEscaper = strings.NewReplacer(
+	LikeEscapeChar, LikeEscapeChar+LikeEscapeChar,
+	"%", LikeEscapeChar+"%",
+	"_", LikeEscapeChar+"_",
+)
+
+// UserFilter ユーザー検索の条件
+// ゼロ値のフィールドは条件に含めない。指定したフィールドはすべてANDで結合する
+type UserFilter struct {
+	// NameContains 名前の部分一致
+	NameContains string
+
+	// EmailDomain メールアドレスの@より後ろの完全一致
+	EmailDomain string
+
+	// CreatedFrom 作成日時の下限（この時刻を含む）
+	CreatedFrom time.Time
+
+	// CreatedTo 作成日時の上限（この時刻を含まない）
+	CreatedTo time.Time
+
+	// IncludeDeleted 論理削除したユーザーも含める
+	IncludeDeleted bool
+}
+
+// EscapeLike LIKEのパターンとして文字通りに一致するようにエスケープする
+// 生成したパターンはESCAPE句にLikeEscapeCharを指定して使う
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,20 @@
+package model
+
+import "testing"
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"abc":    "abc",
+		"50%":    "50!%",
+		"a_b":    "a!_b",
+		"wow!":   "wow!!",
+		"!%_":    "!!!%!_",
+		"山田":     "山田",
+		`back\s`: `back\s`,
+	}
+	for in, want := range tests {
+		if got := EscapeLike(in); got != want {
+			t.Errorf("EscapeLike(%q): 期待する値: %q, 実際: %q", in, want, got)
+		}
+	}
+}
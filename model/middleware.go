@@ -0,0 +1,15 @@
+package model
+
+// Middleware リポジトリを包み、ログや計測などの横断的な処理を追加する関数
+// 各実装に手を入れずに、どのライブラリのリポジトリにも同じ処理を追加できる
+type Middleware func(UserRepository) UserRepository
+
+// Chain repoをmiddlewaresで包んだリポジトリを返す
+// 先に指定したミドルウェアほど外側になり、呼び出しはmiddlewaresの順に通ってからrepoに届く
+// 返したリポジトリのCloseは、すべてのミドルウェアを通ってrepoのCloseを呼び出す
+func Chain(repo UserRepository, middlewares ...Middleware) UserRepository {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		repo = middlewares[i](repo)
+	}
+	return repo
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCursor カーソルの形式が不正
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorPrefix カーソルに埋め込む種別
+const cursorPrefix = "id:"
+
+// Page キーセットページネーションの条件
+type Page struct {
+	// Limit 取得する最大件数
+	Limit int
+
+	// AfterID このIDより大きいユーザーを取得する（0の場合は先頭から）
+	AfterID int
+}
+
+// EncodeCursor IDをクライアントに渡す不透明なカーソル文字列に変換
+func EncodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(id)))
+}
+
+// DecodeCursor カーソル文字列からIDを取り出す
+func DecodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	s, ok := strings.CutPrefix(string(b), cursorPrefix)
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
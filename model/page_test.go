@@ -0,0 +1,26 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCursor_RoundTrip(t *testing.T) {
+	for _, id := range []int{0, 1, 12345} {
+		got, err := DecodeCursor(EncodeCursor(id))
+		if err != nil {
+			t.Fatalf("DecodeCursor エラー: %v", err)
+		}
+		if got != id {
+			t.Errorf("期待するID: %d, 実際: %d", id, got)
+		}
+	}
+}
+
+func TestDecodeCursor_Invalid(t *testing.T) {
+	for _, cursor := range []string{"!!!", "MTIz", EncodeCursor(-1)} {
+		if _, err := DecodeCursor(cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("カーソル %q: 期待するエラー: ErrInvalidCursor, 実際: %v", cursor, err)
+		}
+	}
+}
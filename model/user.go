@@ -1,32 +1,82 @@
 package model
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // User ユーザーモデル
 type User struct {
-	ID        int       `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Email     string    `json:"email" db:"email"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        int        `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Email     string     `json:"email" db:"email"`
+	Version   int        `json:"version" db:"version"` // 楽観的ロック用のバージョン（作成時は1で、更新のたびに増える）
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"` // 論理削除した日時（削除されていない場合はnil）
+}
+
+// NewUser 新規作成するユーザーの入力
+type NewUser struct {
+	Name  string `json:"name" db:"name"`
+	Email string `json:"email" db:"email"`
+}
+
+// UserPatch 部分更新の内容
+// nilのフィールドは変更せず、空文字を含むnil以外の値はそのまま書き込む
+type UserPatch struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
 }
 
 // UserRepository ユーザーリポジトリのインターフェース
+// 各メソッドは第一引数にcontextを受け取り、キャンセルやタイムアウトをDBまで伝播させる
+// 論理削除したユーザーはRestoreとfilter.IncludeDeletedを指定したList以外からは見えない
 type UserRepository interface {
-	// GetAll 全ユーザーを取得
-	GetAll() ([]User, error)
+	// GetAll 削除されていない全ユーザーを取得
+	GetAll(ctx context.Context) ([]User, error)
+
+	// List filterに一致するユーザーをIDの昇順でpage.AfterIDより後ろから最大page.Limit件取得
+	List(ctx context.Context, filter UserFilter, page Page) ([]User, error)
 
 	// GetByID IDでユーザーを取得
-	GetByID(id int) (*User, error)
+	GetByID(ctx context.Context, id int) (*User, error)
 
 	// Create 新規ユーザーを作成
-	Create(name, email string) (*User, error)
+	Create(ctx context.Context, name, email string) (*User, error)
+
+	// CreateMany 複数のユーザーを複数行INSERTでまとめて作成し、作成したIDを入力順に返す
+	// 途中で失敗した場合はすべてロールバックする
+	CreateMany(ctx context.Context, users []NewUser) ([]int, error)
+
+	// Upsert メールアドレスをキーにユーザーを作成または名前を更新
+	// 新規作成した場合はinsertedがtrueになる。論理削除済みのユーザーは復元する
+	Upsert(ctx context.Context, name, email string) (user *User, inserted bool, err error)
 
 	// Update ユーザー情報を更新
-	Update(id int, name, email string) error
+	// ユーザーが存在しない場合はErrNotFound、versionが現在のバージョンと一致しない場合はErrVersionConflictを返す
+	Update(ctx context.Context, id int, name, email string, version int) error
+
+	// Patch patchで指定したフィールドだけを更新
+	// ユーザーが存在しない場合はErrNotFound、versionが現在のバージョンと一致しない場合はErrVersionConflictを返す
+	Patch(ctx context.Context, id int, patch UserPatch, version int) error
+
+	// Delete ユーザーを論理削除
+	// ユーザーが存在しない場合はErrNotFound、versionが現在のバージョンと一致しない場合はErrVersionConflictを返す
+	Delete(ctx context.Context, id int, version int) error
+
+	// Restore 論理削除したユーザーを復元
+	// 論理削除されたユーザーが存在しない場合はErrNotFoundを返す
+	Restore(ctx context.Context, id int) error
+
+	// Purge olderThanより前に論理削除したユーザーを物理削除し、削除件数を返す
+	Purge(ctx context.Context, olderThan time.Time) (int64, error)
 
-	// Delete ユーザーを削除
-	Delete(id int) error
+	// WithTx fnをトランザクション内で実行する
+	// fnに渡されたリポジトリの操作はすべて同じトランザクションで行われ、
+	// fnがnilを返せばコミット、エラーを返すかパニックした場合はロールバックする
+	// トランザクション内で呼ばれた場合は外側のトランザクションに参加する
+	WithTx(ctx context.Context, fn func(repo UserRepository) error) error
 
 	// Close データベース接続を閉じる
 	Close() error
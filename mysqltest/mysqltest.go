@@ -0,0 +1,57 @@
+// Package mysqltest テスト用のMySQL互換サーバー（go-mysql-server）をプロセス内で起動する
+//
+// DockerやネットワークのないマシンでもMySQLを使う実装のテストを go test ./... だけで実行できる。
+// データはメモリ上にのみ保存され、テストの終了とともに消える。
+package mysqltest
+
+import (
+	"fmt"
+	"testing"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/sirupsen/logrus"
+)
+
+// dbName compose.ymlのMySQLと同じデータベース名
+const dbName = "testdb"
+
+// DSN テスト専用のMySQL互換サーバーを起動し、接続するDSNを返す
+// サーバーはランダムなポートで起動し、テスト終了時に停止する。testdbは空のため、テーブルはマイグレーション（migrateパッケージ）で作成する
+// go-mysql-serverのメモリ上のデータベースは、別のデータベースへのDDLと並行した書き込みが失われることがあるため、
+// 並行に実行するテスト同士でサーバーを共有しない
+func DSN(tb testing.TB) string {
+	tb.Helper()
+	s, dsn, err := start()
+	if err != nil {
+		tb.Fatalf("テスト用MySQLサーバーの起動エラー: %v", err)
+	}
+	tb.Cleanup(func() { s.Close() })
+	return dsn
+}
+
+// start サーバーを起動し、サーバーとDSNを返す
+func start() (*server.Server, string, error) {
+	// 接続ごとの情報ログでテストの出力が埋まらないようにする
+	logrus.SetLevel(logrus.ErrorLevel)
+
+	db := memory.NewDatabase(dbName)
+	db.BaseDatabase.EnablePrimaryKeyIndexes()
+	provider := memory.NewDBProvider(db)
+	engine := sqle.NewDefault(provider)
+
+	cfg := server.Config{Protocol: "tcp", Address: "127.0.0.1:0"}
+	s, err := server.NewServer(cfg, engine, gmssql.NewContext, memory.NewSessionBuilder(provider), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	go s.Start()
+
+	// go-mysql-serverはCURRENT_TIMESTAMPをプロセスのタイムゾーンで求めるが、ドライバはUTCとして読み取るため、
+	// セッションのタイムゾーンをUTCにしてTZの設定にかかわらず同じ日時になるようにする
+	dsn := fmt.Sprintf("root:password@tcp(%s)/%s?parseTime=true&charset=utf8mb4&time_zone=%%27%%2B00%%3A00%%27",
+		s.Listener.Addr(), dbName)
+	return s, dsn, nil
+}
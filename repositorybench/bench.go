@@ -0,0 +1,313 @@
+// Package repositorybench model.UserRepositoryの実装を同じ条件で比較するための共通ベンチマーク
+//
+// 各実装のパッケージはベンチマークからRunを呼び出すだけで、すべてのライブラリが同じコードで計測される。
+package repositorybench
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go_sql_library/model"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Factory ベンチマークごとに新しいリポジトリを返す
+// ベンチマークごとに独立したデータベース（dbtestパッケージ）を使うため、作成したデータを削除する必要はない
+// 接続のCloseはb.Cleanupで登録すること
+type Factory func(b *testing.B) model.UserRepository
+
+// ConfigurePool 並行ベンチマークの条件を揃えるため、dbのコネクションプールを設定する
+func ConfigurePool(db *sql.DB) {
+	db.SetMaxOpenConns(100)
+	db.SetMaxIdleConns(10)
+}
+
+// seedBatchSize テストデータをまとめて作成するときの1回あたりの件数
+const seedBatchSize = 1000
+
+// Run 共通のベンチマークをサブベンチマークとして実行する
+func Run(b *testing.B, newRepo Factory) {
+	benchmarks := []struct {
+		name string
+		fn   func(b *testing.B, e *env)
+	}{
+		{"GetAll", benchGetAll},
+		{"GetByID", benchGetByID},
+		{"List", benchList},
+		{"Create", benchCreate},
+		{"Update", benchUpdate},
+		{"ConcurrentReads", benchConcurrentReads},
+		{"ConcurrentWrites", benchConcurrentWrites},
+		{"MixedReadWrite", benchMixedReadWrite},
+		{"SingleRowContention", benchSingleRowContention},
+		{"BulkInsert", benchBulkInsert},
+		{"CreateMany", benchCreateMany},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			repo := newRepo(b)
+			// データベースの作成とマイグレーションの時間を計測に含めない
+			b.ResetTimer()
+
+			bm.fn(b, &env{
+				repo: repo,
+				ctx:  context.Background(),
+				id:   strconv.FormatInt(time.Now().UnixNano(), 36),
+			})
+		})
+	}
+}
+
+// env ベンチマークで使うリポジトリと補助関数
+type env struct {
+	repo model.UserRepository
+	ctx  context.Context
+
+	// id このベンチマークの識別子（メールアドレスの重複を避けるため）
+	id string
+
+	// seq メールアドレスを一意にするための連番
+	seq atomic.Int64
+}
+
+// email このベンチマーク専用のメールアドレスを作る
+func (e *env) email(label string) string {
+	return fmt.Sprintf("bench_%s_%s_%d@example.com", label, e.id, e.seq.Add(1))
+}
+
+// seed n件のユーザーをまとめて作成し、IDを返す（計測対象外）
+func (e *env) seed(b *testing.B, label string, n int) []int {
+	b.Helper()
+	ids := make([]int, 0, n)
+	for len(ids) < n {
+		users := make([]model.NewUser, min(seedBatchSize, n-len(ids)))
+		for i := range users {
+			users[i] = model.NewUser{Name: "ベンチ" + label, Email: e.email(label)}
+		}
+		created, err := e.repo.CreateMany(e.ctx, users)
+		if err != nil {
+			b.Fatalf("テストデータ作成エラー: %v", err)
+		}
+		ids = append(ids, created...)
+	}
+	return ids
+}
+
+// benchGetAll テーブルの件数ごとの全ユーザー取得
+// 既存のデータに追加してテーブル全体がおよそ指定件数になるようにし、実際の取得件数をrows/opとして出力する
+func benchGetAll(b *testing.B, e *env) {
+	for _, rows := range []int{10, 1000, 100000} {
+		b.Run(fmt.Sprintf("Rows%d", rows), func(b *testing.B) {
+			if rows > 1000 && testing.Short() {
+				b.Skip("-shortでは大量データのベンチマークを省略します")
+			}
+
+			existing, err := e.repo.GetAll(e.ctx)
+			if err != nil {
+				b.Fatalf("GetAll エラー: %v", err)
+			}
+			if len(existing) < rows {
+				e.seed(b, "getall", rows-len(existing))
+			}
+
+			var got int
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				users, err := e.repo.GetAll(e.ctx)
+				if err != nil {
+					b.Fatalf("GetAll エラー: %v", err)
+				}
+				got = len(users)
+			}
+			b.ReportMetric(float64(got), "rows/op")
+		})
+	}
+}
+
+// benchGetByID ランダムなIDでのユーザー取得
+func benchGetByID(b *testing.B, e *env) {
+	ids := e.seed(b, "getbyid", 1000)
+
+	b.Run("Random", func(b *testing.B) {
+		r := rand.New(rand.NewPCG(1, 2))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := e.repo.GetByID(e.ctx, ids[r.IntN(len(ids))]); err != nil {
+				b.Fatalf("GetByID エラー: %v", err)
+			}
+		}
+	})
+}
+
+// benchList キーセットページネーションで1000件をたどる
+func benchList(b *testing.B, e *env) {
+	ids := e.seed(b, "list", 1000)
+	first, last := ids[0]-1, ids[len(ids)-1]
+
+	for _, limit := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("Limit%d", limit), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				page := model.Page{Limit: limit, AfterID: first}
+				for page.AfterID < last {
+					users, err := e.repo.List(e.ctx, model.UserFilter{}, page)
+					if err != nil {
+						b.Fatalf("List エラー: %v", err)
+					}
+					if len(users) == 0 {
+						break
+					}
+					page.AfterID = users[len(users)-1].ID
+				}
+			}
+		})
+	}
+}
+
+// benchCreate ユーザー作成
+func benchCreate(b *testing.B, e *env) {
+	for i := 0; i < b.N; i++ {
+		if _, err := e.repo.Create(e.ctx, "ベンチユーザー", e.email("create")); err != nil {
+			b.Fatalf("Create エラー: %v", err)
+		}
+	}
+}
+
+// benchUpdate ユーザー更新
+func benchUpdate(b *testing.B, e *env) {
+	user, err := e.repo.Create(e.ctx, "更新ベンチ", e.email("update"))
+	if err != nil {
+		b.Fatalf("テストデータ作成エラー: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := e.repo.Update(e.ctx, user.ID, fmt.Sprintf("更新%d", i), user.Email, user.Version+i); err != nil {
+			b.Fatalf("Update エラー: %v", err)
+		}
+	}
+}
+
+// benchConcurrentReads 並行読み取り
+func benchConcurrentReads(b *testing.B, e *env) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := e.repo.GetAll(e.ctx); err != nil {
+				b.Errorf("GetAll エラー: %v", err)
+			}
+		}
+	})
+}
+
+// benchConcurrentWrites 並行書き込み
+func benchConcurrentWrites(b *testing.B, e *env) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := e.repo.Create(e.ctx, "並行ベンチ", e.email("concurrent")); err != nil {
+				b.Errorf("Create エラー: %v", err)
+			}
+		}
+	})
+}
+
+// benchMixedReadWrite 読み取りと書き込みを指定した割合で並行に実行
+// 読み取りはランダムなIDでのGetByID、書き込みはランダムなユーザーのUpsertで行う
+func benchMixedReadWrite(b *testing.B, e *env) {
+	ids := e.seed(b, "mixed", 1000)
+	emails := make([]string, len(ids))
+	for i, id := range ids {
+		u, err := e.repo.GetByID(e.ctx, id)
+		if err != nil {
+			b.Fatalf("GetByID エラー: %v", err)
+		}
+		emails[i] = u.Email
+	}
+
+	for _, readPercent := range []int{90, 50, 10} {
+		b.Run(fmt.Sprintf("Read%dWrite%d", readPercent, 100-readPercent), func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := rand.IntN(len(ids))
+					if rand.IntN(100) < readPercent {
+						if _, err := e.repo.GetByID(e.ctx, ids[i]); err != nil {
+							b.Errorf("GetByID エラー: %v", err)
+						}
+						continue
+					}
+					if _, _, err := e.repo.Upsert(e.ctx, "混在ベンチ", emails[i]); err != nil {
+						b.Errorf("Upsert エラー: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// benchSingleRowContention 1行に更新が集中する場合の楽観的ロック
+// 取得したバージョンで更新し、競合した割合をconflicts/opとして出力する
+func benchSingleRowContention(b *testing.B, e *env) {
+	user, err := e.repo.Create(e.ctx, "競合ベンチ", e.email("contention"))
+	if err != nil {
+		b.Fatalf("テストデータ作成エラー: %v", err)
+	}
+
+	var conflicts atomic.Int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			u, err := e.repo.GetByID(e.ctx, user.ID)
+			if err != nil {
+				b.Errorf("GetByID エラー: %v", err)
+				continue
+			}
+			err = e.repo.Update(e.ctx, u.ID, "競合ベンチ更新", u.Email, u.Version)
+			switch {
+			case errors.Is(err, model.ErrVersionConflict):
+				conflicts.Add(1)
+			case err != nil:
+				b.Errorf("Update エラー: %v", err)
+			}
+		}
+	})
+	b.ReportMetric(float64(conflicts.Load())/float64(b.N), "conflicts/op")
+}
+
+// benchBulkInsert Createを繰り返し呼ぶ大量挿入
+func benchBulkInsert(b *testing.B, e *env) {
+	for _, count := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("Insert%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < count; j++ {
+					if _, err := e.repo.Create(e.ctx, "一括ベンチ", e.email("bulk")); err != nil {
+						b.Fatalf("Create エラー: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// benchCreateMany CreateManyによる一括挿入（BulkInsertとの比較用）
+func benchCreateMany(b *testing.B, e *env) {
+	for _, count := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("Insert%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				users := make([]model.NewUser, count)
+				for j := range users {
+					users[j] = model.NewUser{Name: "一括ベンチ", Email: e.email("many")}
+				}
+				b.StartTimer()
+
+				if _, err := e.repo.CreateMany(e.ctx, users); err != nil {
+					b.Fatalf("CreateMany エラー: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,797 @@
+// Package repositorytest model.UserRepositoryの実装が共通で満たすべき振る舞いのテスト
+//
+// 各実装のパッケージはテストからRunConformanceを呼び出すだけで、同じテストケースで検証される。
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go_sql_library/model"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Factory テストケースごとに新しいリポジトリを作る
+// テストケースは並行に実行されるため、テストケースごとに独立したデータベース（dbtestパッケージ）を使うこと
+// テストデータの削除や接続のCloseなどの後片付けは、t.Cleanupで登録すること
+// 作成するユーザーのメールアドレスはすべて test%@example.com に一致する
+type Factory func(t *testing.T) model.UserRepository
+
+// RunConformance model.UserRepositoryの共通テストをサブテストとして実行する
+func RunConformance(t *testing.T, newRepo Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *suite)
+	}{
+		{"GetAll", testGetAll},
+		{"GetAll_Canceled", testGetAllCanceled},
+		{"Create", testCreate},
+		{"Create_DuplicateEmail", testCreateDuplicateEmail},
+		{"Create_UnicodeName", testCreateUnicodeName},
+		{"GetByID", testGetByID},
+		{"GetByID_NotFound", testGetByIDNotFound},
+		{"Timestamps", testTimestamps},
+		{"List", testList},
+		{"List_Filter", testListFilter},
+		{"CreateMany", testCreateMany},
+		{"CreateMany_DuplicateEmail", testCreateManyDuplicateEmail},
+		{"Upsert", testUpsert},
+		{"Update", testUpdate},
+		{"Update_EmptyName", testUpdateEmptyName},
+		{"Update_SameValues", testUpdateSameValues},
+		{"Update_NotFound", testUpdateNotFound},
+		{"Update_VersionConflict", testUpdateVersionConflict},
+		{"Update_Concurrent", testUpdateConcurrent},
+		{"Patch", testPatch},
+		{"Delete", testDelete},
+		{"Restore", testRestore},
+		{"Purge", testPurge},
+		{"WithTx_Commit", testWithTxCommit},
+		{"WithTx_Rollback", testWithTxRollback},
+		{"WithTx_Panic", testWithTxPanic},
+		{"Concurrent", testConcurrent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.fn(t, &suite{
+				repo: newRepo(t),
+				ctx:  context.Background(),
+				id:   newRunID(),
+			})
+		})
+	}
+}
+
+// runSeq 同じプロセス内で実行IDが重複しないようにするための連番
+var runSeq atomic.Int64
+
+// newRunID テストケースごとに一意な識別子を作る
+// 複数のパッケージのテストが同じデータベースに並行して書き込むため、メールアドレスや名前に含めて衝突を避ける
+func newRunID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36) + strconv.FormatInt(runSeq.Add(1), 36)
+}
+
+// suite テストケースで使うリポジトリと補助関数
+type suite struct {
+	repo model.UserRepository
+	ctx  context.Context
+
+	// id このテストケースの識別子
+	id string
+}
+
+// email このテストケース専用のメールアドレスを作る
+func (s *suite) email(label string) string {
+	return fmt.Sprintf("test_%s_%s@example.com", label, s.id)
+}
+
+// name このテストケース専用の名前を作る（検索条件で他のデータと区別するため）
+func (s *suite) name(label string) string {
+	return label + s.id
+}
+
+// mustCreate ユーザーを作成し、失敗した場合はテストを中断する
+func (s *suite) mustCreate(t *testing.T, name, email string) *model.User {
+	t.Helper()
+	u, err := s.repo.Create(s.ctx, name, email)
+	if err != nil {
+		t.Fatalf("Create エラー: %v", err)
+	}
+	return u
+}
+
+// mustGet ユーザーを取得し、失敗した場合はテストを中断する
+func (s *suite) mustGet(t *testing.T, id int) *model.User {
+	t.Helper()
+	u, err := s.repo.GetByID(s.ctx, id)
+	if err != nil {
+		t.Fatalf("GetByID エラー: %v", err)
+	}
+	return u
+}
+
+// wantErr errがtargetでなければテストを失敗させる
+func wantErr(t *testing.T, label string, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Errorf("%s: 期待するエラー: %v, 実際: %v", label, target, err)
+	}
+}
+
+func testGetAll(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "全件取得", s.email("getall"))
+
+	users, err := s.repo.GetAll(s.ctx)
+	if err != nil {
+		t.Fatalf("GetAll エラー: %v", err)
+	}
+
+	found := false
+	for i, u := range users {
+		if i > 0 && users[i-1].ID >= u.ID {
+			t.Fatalf("IDが昇順になっていません: %d >= %d", users[i-1].ID, u.ID)
+		}
+		if u.ID == created.ID {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("作成したユーザーが取得できませんでした")
+	}
+}
+
+func testGetAllCanceled(t *testing.T, s *suite) {
+	// キャンセル済みのcontextではクエリが実行されないことを確認
+	ctx, cancel := context.WithCancel(s.ctx)
+	cancel()
+
+	_, err := s.repo.GetAll(ctx)
+	wantErr(t, "GetAll", err, context.Canceled)
+}
+
+func testCreate(t *testing.T, s *suite) {
+	email := s.email("create")
+	user := s.mustCreate(t, "テストユーザー", email)
+
+	if user.ID == 0 {
+		t.Error("ユーザーIDが設定されていません")
+	}
+	if user.Name != "テストユーザー" {
+		t.Errorf("期待する名前: テストユーザー, 実際: %s", user.Name)
+	}
+	if user.Email != email {
+		t.Errorf("期待するメール: %s, 実際: %s", email, user.Email)
+	}
+	if user.Version != 1 {
+		t.Errorf("期待するバージョン: 1, 実際: %d", user.Version)
+	}
+	if user.DeletedAt != nil {
+		t.Errorf("作成直後にDeletedAtが設定されています: %v", user.DeletedAt)
+	}
+}
+
+func testCreateDuplicateEmail(t *testing.T, s *suite) {
+	email := s.email("dup")
+	s.mustCreate(t, "重複元", email)
+
+	// 同じメールアドレスでの作成は重複エラーになる
+	_, err := s.repo.Create(s.ctx, "重複", email)
+	wantErr(t, "Create", err, model.ErrDuplicateEmail)
+	if !errors.Is(err, model.ErrConflict) {
+		t.Errorf("重複エラーはmodel.ErrConflictとしても判定できる必要があります: %v", err)
+	}
+}
+
+func testCreateUnicodeName(t *testing.T, s *suite) {
+	// 4バイト文字（絵文字、サロゲートペアの漢字）や結合文字もそのまま保存される
+	names := []string{
+		"山田😀太郎",
+		"𠮷野家",
+		"Zoë Ångström",
+		"école",
+		"Ελληνικά and عربى",
+	}
+	for i, name := range names {
+		created := s.mustCreate(t, name, s.email(fmt.Sprintf("unicode%d", i)))
+		if created.Name != name {
+			t.Errorf("Create: 期待する名前: %q, 実際: %q", name, created.Name)
+		}
+		if got := s.mustGet(t, created.ID).Name; got != name {
+			t.Errorf("GetByID: 期待する名前: %q, 実際: %q", name, got)
+		}
+	}
+
+	// 絵文字を含む名前でも部分一致で検索できる
+	users, err := s.repo.List(s.ctx, model.UserFilter{NameContains: "😀太"}, model.Page{Limit: 100})
+	if err != nil {
+		t.Fatalf("List エラー: %v", err)
+	}
+	if len(users) == 0 {
+		t.Error("絵文字を含む名前で検索できませんでした")
+	}
+}
+
+func testGetByID(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "テストユーザー2", s.email("getbyid"))
+
+	user := s.mustGet(t, created.ID)
+	if user.ID != created.ID {
+		t.Errorf("期待するID: %d, 実際: %d", created.ID, user.ID)
+	}
+	if user.Name != "テストユーザー2" {
+		t.Errorf("期待する名前: テストユーザー2, 実際: %s", user.Name)
+	}
+	if user.Email != created.Email {
+		t.Errorf("期待するメール: %s, 実際: %s", created.Email, user.Email)
+	}
+}
+
+func testGetByIDNotFound(t *testing.T, s *suite) {
+	_, err := s.repo.GetByID(s.ctx, -1)
+	wantErr(t, "GetByID", err, model.ErrNotFound)
+}
+
+func testTimestamps(t *testing.T, s *suite) {
+	// 作成日時と更新日時は秒単位で保存されるため、取得し直した値で比較する
+	created := s.mustCreate(t, "日時", s.email("timestamps"))
+	before := s.mustGet(t, created.ID)
+
+	if d := time.Since(before.CreatedAt); d < -time.Minute || d > time.Minute {
+		t.Errorf("作成日時が現在時刻とずれています: %v（タイムゾーンの設定を確認してください）", before.CreatedAt)
+	}
+	if before.UpdatedAt.Before(before.CreatedAt) {
+		t.Errorf("更新日時が作成日時より前です: created_at=%v, updated_at=%v", before.CreatedAt, before.UpdatedAt)
+	}
+
+	// 秒が変わるまで待ってから更新する
+	time.Sleep(1100 * time.Millisecond)
+	if err := s.repo.Update(s.ctx, before.ID, "日時更新", before.Email, before.Version); err != nil {
+		t.Fatalf("Update エラー: %v", err)
+	}
+
+	after := s.mustGet(t, created.ID)
+	if !after.CreatedAt.Equal(before.CreatedAt) {
+		t.Errorf("更新で作成日時が変わりました: %v -> %v", before.CreatedAt, after.CreatedAt)
+	}
+	if !after.UpdatedAt.After(before.UpdatedAt) {
+		t.Errorf("更新日時が進んでいません: %v -> %v", before.UpdatedAt, after.UpdatedAt)
+	}
+}
+
+func testList(t *testing.T, s *suite) {
+	var ids []int
+	for i := 0; i < 3; i++ {
+		u := s.mustCreate(t, "ページ", s.email(fmt.Sprintf("page%d", i)))
+		ids = append(ids, u.ID)
+	}
+
+	// 先頭ページ
+	users, err := s.repo.List(s.ctx, model.UserFilter{}, model.Page{Limit: 2, AfterID: ids[0] - 1})
+	if err != nil {
+		t.Fatalf("List エラー: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("期待する件数: 2, 実際: %d", len(users))
+	}
+	if users[0].ID != ids[0] {
+		t.Errorf("期待するID: %d, 実際: %d", ids[0], users[0].ID)
+	}
+
+	// カーソルを進めながら全件をたどり、作成したユーザーが昇順で取得できることを確認
+	found := map[int]bool{}
+	afterID := ids[0] - 1
+	for {
+		users, err := s.repo.List(s.ctx, model.UserFilter{}, model.Page{Limit: 2, AfterID: afterID})
+		if err != nil {
+			t.Fatalf("List エラー: %v", err)
+		}
+		if len(users) == 0 {
+			break
+		}
+		for _, u := range users {
+			if u.ID <= afterID {
+				t.Fatalf("IDが昇順になっていません: %d <= %d", u.ID, afterID)
+			}
+			afterID = u.ID
+			found[u.ID] = true
+		}
+	}
+	for _, id := range ids {
+		if !found[id] {
+			t.Errorf("ID %d が取得できませんでした", id)
+		}
+	}
+}
+
+func testListFilter(t *testing.T, s *suite) {
+	match := s.name("検索")
+	other := s.name("対象外")
+	inputs := []struct{ name, email string }{
+		{match + "_太郎", s.email("filter1")},
+		{match + "%花子", s.email("filter2")},
+		{other, s.email("filter3")},
+	}
+	var ids []int
+	for _, in := range inputs {
+		ids = append(ids, s.mustCreate(t, in.name, in.email).ID)
+	}
+	page := model.Page{Limit: 100, AfterID: ids[0] - 1}
+	// 保存した作成日時（取得し直した値）は下限に含まれる
+	createdAt := s.mustGet(t, ids[2]).CreatedAt
+
+	tests := []struct {
+		name   string
+		filter model.UserFilter
+		want   []int
+	}{
+		{"名前の部分一致", model.UserFilter{NameContains: match}, ids[:2]},
+		{"ワイルドカードはエスケープされる", model.UserFilter{NameContains: match + "%"}, ids[1:2]},
+		{"メールドメイン", model.UserFilter{NameContains: other, EmailDomain: "example.com"}, ids[2:]},
+		{"ドメインは完全一致", model.UserFilter{NameContains: match, EmailDomain: "ample.com"}, nil},
+		{"作成日時の範囲外", model.UserFilter{NameContains: match, CreatedTo: time.Now().Add(-time.Hour)}, nil},
+		{"作成日時の範囲内", model.UserFilter{NameContains: match, CreatedFrom: time.Now().Add(-time.Hour)}, ids[:2]},
+		{"作成日時の下限は含む", model.UserFilter{NameContains: other, CreatedFrom: createdAt}, ids[2:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := s.repo.List(s.ctx, tt.filter, page)
+			if err != nil {
+				t.Fatalf("List エラー: %v", err)
+			}
+			var got []int
+			for _, u := range users {
+				got = append(got, u.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("期待するID: %v, 実際: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func testCreateMany(t *testing.T, s *suite) {
+	var input []model.NewUser
+	for i := 0; i < 3; i++ {
+		input = append(input, model.NewUser{
+			Name:  fmt.Sprintf("一括%d", i),
+			Email: s.email(fmt.Sprintf("many%d", i)),
+		})
+	}
+
+	ids, err := s.repo.CreateMany(s.ctx, input)
+	if err != nil {
+		t.Fatalf("CreateMany エラー: %v", err)
+	}
+	if len(ids) != len(input) {
+		t.Fatalf("期待するID数: %d, 実際: %d", len(input), len(ids))
+	}
+
+	// 返されたIDが入力順のユーザーに対応していることを確認
+	for i, id := range ids {
+		user := s.mustGet(t, id)
+		if user.Email != input[i].Email {
+			t.Errorf("ID %d: 期待するメール: %s, 実際: %s", id, input[i].Email, user.Email)
+		}
+	}
+
+	// 空の入力ではエラーにならない
+	ids, err = s.repo.CreateMany(s.ctx, nil)
+	if err != nil {
+		t.Fatalf("空のCreateMany エラー: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("期待するID数: 0, 実際: %d", len(ids))
+	}
+}
+
+func testCreateManyDuplicateEmail(t *testing.T, s *suite) {
+	name := s.name("一括重複")
+	input := []model.NewUser{
+		{Name: name, Email: s.email("manydup")},
+		{Name: name, Email: s.email("manydup")},
+	}
+	_, err := s.repo.CreateMany(s.ctx, input)
+	wantErr(t, "CreateMany", err, model.ErrDuplicateEmail)
+
+	// 1件も作成されていないことを確認
+	users, err := s.repo.List(s.ctx, model.UserFilter{NameContains: name}, model.Page{Limit: 10})
+	if err != nil {
+		t.Fatalf("List エラー: %v", err)
+	}
+	if len(users) != 0 {
+		t.Errorf("失敗したCreateManyのユーザーが残っています: %+v", users)
+	}
+}
+
+func testUpsert(t *testing.T, s *suite) {
+	email := s.email("upsert")
+
+	// 存在しないメールアドレスは新規作成
+	created, inserted, err := s.repo.Upsert(s.ctx, "アップサート", email)
+	if err != nil {
+		t.Fatalf("Upsert エラー: %v", err)
+	}
+	if !inserted {
+		t.Error("新規作成なのにinsertedがfalseです")
+	}
+
+	// 既存のメールアドレスは名前を更新
+	updated, inserted, err := s.repo.Upsert(s.ctx, "アップサート更新", email)
+	if err != nil {
+		t.Fatalf("Upsert エラー: %v", err)
+	}
+	if inserted {
+		t.Error("更新なのにinsertedがtrueです")
+	}
+	if updated.ID != created.ID {
+		t.Errorf("期待するID: %d, 実際: %d", created.ID, updated.ID)
+	}
+	if updated.Name != "アップサート更新" {
+		t.Errorf("期待する名前: アップサート更新, 実際: %s", updated.Name)
+	}
+
+	// 論理削除済みのユーザーは復元される
+	if err := s.repo.Delete(s.ctx, updated.ID, updated.Version); err != nil {
+		t.Fatalf("Delete エラー: %v", err)
+	}
+	restored, inserted, err := s.repo.Upsert(s.ctx, "アップサート復元", email)
+	if err != nil {
+		t.Fatalf("Upsert エラー: %v", err)
+	}
+	if inserted || restored.ID != created.ID || restored.DeletedAt != nil {
+		t.Errorf("論理削除済みのユーザーが復元されていません: inserted=%v, %+v", inserted, restored)
+	}
+}
+
+func testUpdate(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "更新前", s.email("update"))
+
+	newEmail := s.email("updated")
+	if err := s.repo.Update(s.ctx, created.ID, "更新後", newEmail, created.Version); err != nil {
+		t.Fatalf("Update エラー: %v", err)
+	}
+
+	user := s.mustGet(t, created.ID)
+	if user.Name != "更新後" {
+		t.Errorf("期待する名前: 更新後, 実際: %s", user.Name)
+	}
+	if user.Email != newEmail {
+		t.Errorf("期待するメール: %s, 実際: %s", newEmail, user.Email)
+	}
+	if user.Version != created.Version+1 {
+		t.Errorf("期待するバージョン: %d, 実際: %d", created.Version+1, user.Version)
+	}
+
+	// 他のユーザーのメールアドレスへの変更は重複エラーになる
+	other := s.mustCreate(t, "他のユーザー", s.email("update_other"))
+	err := s.repo.Update(s.ctx, created.ID, "更新後", other.Email, user.Version)
+	wantErr(t, "Update", err, model.ErrDuplicateEmail)
+}
+
+func testUpdateEmptyName(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "空文字更新", s.email("empty"))
+
+	// Updateは空文字も含めて全フィールドを書き込む
+	if err := s.repo.Update(s.ctx, created.ID, "", created.Email, created.Version); err != nil {
+		t.Fatalf("Update エラー: %v", err)
+	}
+
+	if user := s.mustGet(t, created.ID); user.Name != "" {
+		t.Errorf("期待する名前: 空文字, 実際: %q", user.Name)
+	}
+}
+
+func testUpdateSameValues(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "同じ値", s.email("same"))
+
+	// 値が変わらない更新でもversionが増えるため、影響行数0による誤検知は起きない
+	if err := s.repo.Update(s.ctx, created.ID, created.Name, created.Email, created.Version); err != nil {
+		t.Fatalf("Update エラー: %v", err)
+	}
+
+	if user := s.mustGet(t, created.ID); user.Version != created.Version+1 {
+		t.Errorf("期待するバージョン: %d, 実際: %d", created.Version+1, user.Version)
+	}
+}
+
+func testUpdateNotFound(t *testing.T, s *suite) {
+	// 存在しないユーザーへの更新・削除は成功扱いにせずErrNotFoundを返す
+	name := "存在しない"
+	wantErr(t, "Update", s.repo.Update(s.ctx, -1, name, s.email("missing"), 1), model.ErrNotFound)
+	wantErr(t, "Patch", s.repo.Patch(s.ctx, -1, model.UserPatch{Name: &name}, 1), model.ErrNotFound)
+	wantErr(t, "Delete", s.repo.Delete(s.ctx, -1, 1), model.ErrNotFound)
+
+	// 論理削除したユーザーも存在しないものとして扱う
+	created := s.mustCreate(t, "削除済み", s.email("missing_deleted"))
+	if err := s.repo.Delete(s.ctx, created.ID, created.Version); err != nil {
+		t.Fatalf("Delete エラー: %v", err)
+	}
+	err := s.repo.Update(s.ctx, created.ID, name, created.Email, created.Version+1)
+	wantErr(t, "削除済みへのUpdate", err, model.ErrNotFound)
+}
+
+func testUpdateVersionConflict(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "バージョン", s.email("version"))
+
+	if err := s.repo.Update(s.ctx, created.ID, "バージョン更新", created.Email, created.Version); err != nil {
+		t.Fatalf("Update エラー: %v", err)
+	}
+	if user := s.mustGet(t, created.ID); user.Version != created.Version+1 {
+		t.Errorf("期待するバージョン: %d, 実際: %d", created.Version+1, user.Version)
+	}
+
+	// 古いバージョンでの更新・削除は競合エラーになる
+	err := s.repo.Update(s.ctx, created.ID, "古いバージョン", created.Email, created.Version)
+	wantErr(t, "Update", err, model.ErrVersionConflict)
+	err = s.repo.Delete(s.ctx, created.ID, created.Version)
+	wantErr(t, "Delete", err, model.ErrVersionConflict)
+}
+
+func testUpdateConcurrent(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "並行更新", s.email("concurrent"))
+
+	// 同じバージョンを指定した並行更新は1つだけ成功する
+	const workers = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- s.repo.Update(s.ctx, created.ID, fmt.Sprintf("並行更新%d", i), created.Email, created.Version)
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, model.ErrVersionConflict):
+			t.Errorf("期待するエラー: model.ErrVersionConflict, 実際: %v", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("期待する成功数: 1, 実際: %d", succeeded)
+	}
+
+	if user := s.mustGet(t, created.ID); user.Version != created.Version+1 {
+		t.Errorf("期待するバージョン: %d, 実際: %d", created.Version+1, user.Version)
+	}
+}
+
+func testPatch(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "部分更新", s.email("patch"))
+
+	name := "部分更新後"
+	empty := ""
+	tests := []struct {
+		name      string
+		patch     model.UserPatch
+		wantName  string
+		wantEmail string
+	}{
+		{"省略したフィールドは変更しない", model.UserPatch{Name: &name}, "部分更新後", created.Email},
+		{"空文字はそのまま書き込む", model.UserPatch{Name: &empty}, "", created.Email},
+		{"何も指定しない場合はバージョンのみ更新", model.UserPatch{}, "", created.Email},
+	}
+
+	version := created.Version
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.repo.Patch(s.ctx, created.ID, tt.patch, version); err != nil {
+				t.Fatalf("Patch エラー: %v", err)
+			}
+			version++
+
+			user := s.mustGet(t, created.ID)
+			if user.Name != tt.wantName || user.Email != tt.wantEmail {
+				t.Errorf("期待する値: (%q, %q), 実際: (%q, %q)", tt.wantName, tt.wantEmail, user.Name, user.Email)
+			}
+			if user.Version != version {
+				t.Errorf("期待するバージョン: %d, 実際: %d", version, user.Version)
+			}
+		})
+	}
+
+	// 古いバージョンでの部分更新は競合エラーになる
+	err := s.repo.Patch(s.ctx, created.ID, model.UserPatch{Name: &name}, created.Version)
+	wantErr(t, "Patch", err, model.ErrVersionConflict)
+}
+
+func testDelete(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "削除用", s.email("delete"))
+
+	if err := s.repo.Delete(s.ctx, created.ID, created.Version); err != nil {
+		t.Fatalf("Delete エラー: %v", err)
+	}
+
+	// 論理削除したユーザーはGetByIDとGetAllから見えなくなる
+	_, err := s.repo.GetByID(s.ctx, created.ID)
+	wantErr(t, "GetByID", err, model.ErrNotFound)
+
+	users, err := s.repo.GetAll(s.ctx)
+	if err != nil {
+		t.Fatalf("GetAll エラー: %v", err)
+	}
+	for _, u := range users {
+		if u.ID == created.ID {
+			t.Error("論理削除したユーザーがGetAllで取得できてしまいました")
+		}
+	}
+}
+
+func testRestore(t *testing.T, s *suite) {
+	name := s.name("復元用")
+	created := s.mustCreate(t, name, s.email("restore"))
+
+	// 削除されていないユーザーは復元できない
+	wantErr(t, "Restore", s.repo.Restore(s.ctx, created.ID), model.ErrNotFound)
+
+	if err := s.repo.Delete(s.ctx, created.ID, created.Version); err != nil {
+		t.Fatalf("Delete エラー: %v", err)
+	}
+
+	// 論理削除したユーザーは検索条件で含めた場合のみ取得できる
+	filter := model.UserFilter{NameContains: name}
+	page := model.Page{Limit: 1, AfterID: created.ID - 1}
+	users, err := s.repo.List(s.ctx, filter, page)
+	if err != nil {
+		t.Fatalf("List エラー: %v", err)
+	}
+	if len(users) != 0 {
+		t.Error("論理削除したユーザーが取得できてしまいました")
+	}
+	filter.IncludeDeleted = true
+	users, err = s.repo.List(s.ctx, filter, page)
+	if err != nil {
+		t.Fatalf("List エラー: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != created.ID || users[0].DeletedAt == nil {
+		t.Errorf("論理削除したユーザーが取得できませんでした: %+v", users)
+	}
+
+	if err := s.repo.Restore(s.ctx, created.ID); err != nil {
+		t.Fatalf("Restore エラー: %v", err)
+	}
+
+	if user := s.mustGet(t, created.ID); user.DeletedAt != nil {
+		t.Errorf("復元後もDeletedAtが設定されています: %v", user.DeletedAt)
+	}
+}
+
+func testPurge(t *testing.T, s *suite) {
+	created := s.mustCreate(t, "物理削除用", s.email("purge"))
+
+	if err := s.repo.Delete(s.ctx, created.ID, created.Version); err != nil {
+		t.Fatalf("Delete エラー: %v", err)
+	}
+
+	purged, err := s.repo.Purge(s.ctx, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("Purge エラー: %v", err)
+	}
+	// テストケースごとに独立したデータベースのため、削除されるのはこのユーザーだけになる
+	if purged != 1 {
+		t.Errorf("期待する削除件数: 1, 実際: %d", purged)
+	}
+
+	// 物理削除したユーザーは復元できない
+	wantErr(t, "Restore", s.repo.Restore(s.ctx, created.ID), model.ErrNotFound)
+}
+
+func testWithTxCommit(t *testing.T, s *suite) {
+	var createdID int
+	err := s.repo.WithTx(s.ctx, func(tx model.UserRepository) error {
+		u, err := tx.Create(s.ctx, "コミット前", s.email("tx_commit"))
+		if err != nil {
+			return err
+		}
+		createdID = u.ID
+		return tx.Update(s.ctx, u.ID, "コミット後", u.Email, u.Version)
+	})
+	if err != nil {
+		t.Fatalf("WithTx エラー: %v", err)
+	}
+
+	if user := s.mustGet(t, createdID); user.Name != "コミット後" {
+		t.Errorf("期待する名前: コミット後, 実際: %s", user.Name)
+	}
+}
+
+func testWithTxRollback(t *testing.T, s *suite) {
+	errAbort := errors.New("abort")
+	var createdID int
+	err := s.repo.WithTx(s.ctx, func(tx model.UserRepository) error {
+		u, err := tx.Create(s.ctx, "ロールバック", s.email("tx_rollback"))
+		if err != nil {
+			return err
+		}
+		createdID = u.ID
+		if err := tx.Update(s.ctx, u.ID, "ロールバック更新", u.Email, u.Version); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("期待するエラー: %v, 実際: %v", errAbort, err)
+	}
+
+	// ロールバックされたユーザーは存在しない
+	_, err = s.repo.GetByID(s.ctx, createdID)
+	wantErr(t, "GetByID", err, model.ErrNotFound)
+}
+
+func testWithTxPanic(t *testing.T, s *suite) {
+	var createdID int
+	func() {
+		defer func() {
+			if p := recover(); p == nil {
+				t.Error("パニックが呼び出し元に伝播していません")
+			}
+		}()
+		s.repo.WithTx(s.ctx, func(tx model.UserRepository) error {
+			u, err := tx.Create(s.ctx, "パニック", s.email("tx_panic"))
+			if err != nil {
+				t.Fatalf("Create エラー: %v", err)
+			}
+			createdID = u.ID
+			panic("boom")
+		})
+	}()
+
+	// パニック時もロールバックされる
+	_, err := s.repo.GetByID(s.ctx, createdID)
+	wantErr(t, "GetByID", err, model.ErrNotFound)
+}
+
+func testConcurrent(t *testing.T, s *suite) {
+	// 1つのリポジトリを複数のゴルーチンから同時に使っても、作成と取得が正しく行える
+	const workers = 10
+	var wg sync.WaitGroup
+	ids := make([]int, workers)
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			u, err := s.repo.Create(s.ctx, fmt.Sprintf("並行作成%d", i), s.email(fmt.Sprintf("parallel%d", i)))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			got, err := s.repo.GetByID(s.ctx, u.ID)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			if got.Email != u.Email {
+				errs[i] = fmt.Errorf("ID %d: 期待するメール: %s, 実際: %s", u.ID, u.Email, got.Email)
+				return
+			}
+			ids[i] = u.ID
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[int]bool{}
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("ゴルーチン%d: %v", i, err)
+			continue
+		}
+		if seen[ids[i]] {
+			t.Errorf("IDが重複しています: %d", ids[i])
+		}
+		seen[ids[i]] = true
+	}
+}
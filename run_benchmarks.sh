@@ -49,7 +49,7 @@ RESULT_FILE="$RESULTS_DIR/benchmark_$TIMESTAMP.txt"
     echo ""
 
     # 各パッケージのベンチマーク実行
-    for pkg in standard sqlx gorm ent; do
+    for pkg in standard sqlx gorm ent sqlc bun squirrel memory; do
         echo "========================================"
         echo "$pkg ベンチマーク"
         echo "========================================"
@@ -62,7 +62,7 @@ RESULT_FILE="$RESULTS_DIR/benchmark_$TIMESTAMP.txt"
     echo "========================================"
     echo ""
     echo "全ライブラリの比較:"
-    go test -tags=benchmark -bench=BenchmarkGetAll -benchtime="$BENCHTIME" -benchmem ./standard/... ./sqlx/... ./gorm/... ./ent/... 2>&1 | grep -E "Benchmark|PASS|FAIL|ok"
+    go test -tags=benchmark -bench="Repository/GetAll" -benchtime="$BENCHTIME" -benchmem ./standard/... ./sqlx/... ./gorm/... ./ent/... ./sqlc/... ./bun/... ./squirrel/... ./memory/... 2>&1 | grep -E "Benchmark|PASS|FAIL|ok"
 
 } | tee "$RESULT_FILE"
 
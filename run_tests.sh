@@ -8,19 +8,18 @@ echo "================================"
 echo "Go SQL Library テスト実行"
 echo "================================"
 
-# 環境変数設定
-export DB_HOST=${DB_HOST:-localhost}
-export DB_PORT=${DB_PORT:-3306}
-
-# MySQLが起動しているか確認
+# MySQLコンテナが起動していればそれを使い、なければプロセス内のMySQL互換サーバーで実行する
 echo ""
 echo "MySQLの接続確認..."
-if ! docker exec go_mysql mysqladmin ping -h localhost -u root -ppassword > /dev/null 2>&1; then
-    echo "エラー: MySQLコンテナが起動していません"
-    echo "docker compose up -d を実行してください"
-    exit 1
+if [ -z "$DB_HOST" ] && docker exec go_mysql mysqladmin ping -h localhost -u root -ppassword > /dev/null 2>&1; then
+    export DB_HOST=localhost
+    export DB_PORT=${DB_PORT:-3306}
+fi
+if [ -n "$DB_HOST" ]; then
+    echo "✓ MySQL接続先: $DB_HOST:${DB_PORT:-3306}"
+else
+    echo "✓ MySQLコンテナが起動していないため、プロセス内のMySQL互換サーバー（go-mysql-server）を使います"
 fi
-echo "✓ MySQL接続OK"
 
 # 各パッケージのテスト実行
 echo ""
@@ -43,6 +42,46 @@ echo "4. entのテスト"
 echo "----------------------------"
 go test -v ./ent/...
 
+echo ""
+echo "5. sqlcのテスト"
+echo "----------------------------"
+go test -v ./sqlc/...
+
+echo ""
+echo "6. bunのテスト"
+echo "----------------------------"
+go test -v ./bun/...
+
+echo ""
+echo "7. squirrelのテスト"
+echo "----------------------------"
+go test -v ./squirrel/...
+
+echo ""
+echo "8. memoryのテスト（データベース不要）"
+echo "----------------------------"
+go test -v ./memory/...
+
+echo ""
+echo "9. ミドルウェアのテスト（データベース不要）"
+echo "----------------------------"
+go test -v ./middleware/...
+
+echo ""
+echo "10. マイグレーションのテスト"
+echo "----------------------------"
+go test -v ./migrate/...
+
+echo ""
+echo "11. スキーマドリフト検出のテスト"
+echo "----------------------------"
+go test -v ./schemadrift/...
+
+echo ""
+echo "12. モデルのテスト（データベース不要）"
+echo "----------------------------"
+go test -v ./model/...
+
 echo ""
 echo "================================"
 echo "すべてのテストが完了しました！"
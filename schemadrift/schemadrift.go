@@ -0,0 +1,378 @@
+// Package schemadrift Goのモデルと実際のテーブル定義のずれ（スキーマドリフト）を検出する
+//
+// GORMのモデル（gorm.User）のタグとmodel.Userのdbタグから期待するカラムとインデックスを求め、
+// information_schemaから読み取ったテーブル定義と比較する。
+// 対応しているのはinformation_schemaのあるMySQLとPostgreSQLのみ（PostgreSQLのインデックスはpg_catalogから読み取る）。
+package schemadrift
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go_sql_library/dbdriver"
+	gormRepo "go_sql_library/gorm"
+	"go_sql_library/model"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// Kind 検出したずれの種類
+type Kind string
+
+const (
+	// MissingColumn モデルにあるカラムがテーブルにない
+	MissingColumn Kind = "missing column"
+
+	// UnmappedColumn テーブルにあるカラムがどのモデルにもない
+	UnmappedColumn Kind = "unmapped column"
+
+	// TypeMismatch カラムの型、長さ、NULLの可否がモデルと一致しない
+	TypeMismatch Kind = "type mismatch"
+
+	// MissingIndex モデルのタグにあるインデックスがテーブルにない
+	MissingIndex Kind = "missing index"
+
+	// RedundantIndex 他のインデックスで代わりになる不要なインデックス
+	RedundantIndex Kind = "redundant index"
+)
+
+// Problem 検出したずれ
+type Problem struct {
+	Kind    Kind
+	Target  string // カラム名またはインデックス名
+	Message string
+}
+
+// String 「[種類] 対象: 内容」の形式で返す
+func (p Problem) String() string {
+	return fmt.Sprintf("[%s] %s: %s", p.Kind, p.Target, p.Message)
+}
+
+// 型の分類（ドライバごとの型名の違いを吸収して比較する）
+const (
+	categoryString = "string"
+	categoryInt    = "int"
+	categoryTime   = "time"
+)
+
+// categories information_schemaの型名（PostgreSQLはudt_name）と分類の対応
+var categories = map[string]string{
+	"char": categoryString, "varchar": categoryString, "text": categoryString, "tinytext": categoryString,
+	"mediumtext": categoryString, "longtext": categoryString, "bpchar": categoryString, "citext": categoryString,
+	"tinyint": categoryInt, "smallint": categoryInt, "mediumint": categoryInt, "int": categoryInt,
+	"integer": categoryInt, "bigint": categoryInt, "int2": categoryInt, "int4": categoryInt, "int8": categoryInt,
+	"date": categoryTime, "datetime": categoryTime, "timestamp": categoryTime, "timestamptz": categoryTime,
+}
+
+// sizedType GORMのtypeタグから長さを取り出す（例: varchar(100)）
+var sizedType = regexp.MustCompile(`^\w+\((\d+)\)$`)
+
+// expectedColumn モデルのフィールドから求めた、期待するカラム
+type expectedColumn struct {
+	source   string // 期待しているモデル（例: gorm.User）
+	name     string
+	category string
+	size     int  // typeタグで長さを指定していない場合は0
+	notNull  bool // NOT NULLであること
+	nullable bool // NULLを許可すること（ポインタや論理削除の日時）
+}
+
+// expectedIndex GORMのタグから求めた、期待するインデックス
+type expectedIndex struct {
+	name    string
+	columns []string
+	unique  bool
+}
+
+// column information_schemaから読み取ったカラム
+type column struct {
+	dataType string
+	size     int // 文字列以外や長さのない型は0
+	nullable bool
+}
+
+// index テーブルにあるインデックス
+type index struct {
+	name    string
+	columns []string
+	unique  bool
+	primary bool
+}
+
+// Check dbのテーブル定義をモデルと比較し、検出したずれを返す（ずれがない場合は空）
+func Check(ctx context.Context, db *sql.DB) ([]Problem, error) {
+	driver := dbdriver.Name(db)
+	if driver != dbdriver.MySQL && driver != dbdriver.Postgres {
+		return nil, fmt.Errorf("information_schemaのないデータベースには対応していない: %s", driver)
+	}
+
+	gormSchema, err := schema.Parse(&gormRepo.User{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	table := gormSchema.Table
+
+	columns, err := readColumns(ctx, db, driver, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%sテーブルがない", table)
+	}
+	indexes, err := readIndexes(ctx, db, driver, table)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := append(gormColumns(gormSchema), modelColumns()...)
+	problems := checkColumns(expected, columns)
+	problems = append(problems, checkIndexes(gormIndexes(gormSchema), indexes)...)
+	return problems, nil
+}
+
+// gormColumns GORMのモデルのフィールドとタグから期待するカラムを求める
+func gormColumns(s *schema.Schema) []expectedColumn {
+	var expected []expectedColumn
+	for _, field := range s.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		c := expectedColumn{
+			source:   "gorm.User",
+			name:     field.DBName,
+			notNull:  field.NotNull || field.PrimaryKey,
+			nullable: field.FieldType.Kind() == reflect.Pointer || field.FieldType == reflect.TypeOf(gorm.DeletedAt{}),
+		}
+		switch field.DataType {
+		case schema.String:
+			c.category = categoryString
+		case schema.Int, schema.Uint:
+			c.category = categoryInt
+		case schema.Time:
+			c.category = categoryTime
+		}
+		if m := sizedType.FindStringSubmatch(field.TagSettings["TYPE"]); m != nil {
+			c.size, _ = strconv.Atoi(m[1])
+		}
+		expected = append(expected, c)
+	}
+	return expected
+}
+
+// modelColumns model.Userのdbタグから期待するカラムを求める
+// dbタグには型の指定がないため、Goの型から分類とNULLの可否を求める
+func modelColumns() []expectedColumn {
+	var expected []expectedColumn
+	t := reflect.TypeOf(model.User{})
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := field.Tag.Get("db")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		c := expectedColumn{source: "model.User", name: name}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			c.nullable = true
+			fieldType = fieldType.Elem()
+		}
+		switch {
+		case fieldType == reflect.TypeOf(time.Time{}):
+			c.category = categoryTime
+		case fieldType.Kind() == reflect.String:
+			c.category = categoryString
+		case fieldType.Kind() >= reflect.Int && fieldType.Kind() <= reflect.Uint64:
+			c.category = categoryInt
+		}
+		expected = append(expected, c)
+	}
+	return expected
+}
+
+// gormIndexes GORMのタグ（primaryKey、index、uniqueIndex）から期待するインデックスを求める
+func gormIndexes(s *schema.Schema) []expectedIndex {
+	var expected []expectedIndex
+	if len(s.PrimaryFieldDBNames) > 0 {
+		expected = append(expected, expectedIndex{name: "主キー", columns: s.PrimaryFieldDBNames, unique: true})
+	}
+	for _, idx := range s.ParseIndexes() {
+		e := expectedIndex{name: idx.Name, unique: idx.Class == "UNIQUE"}
+		for _, f := range idx.Fields {
+			e.columns = append(e.columns, f.DBName)
+		}
+		expected = append(expected, e)
+	}
+	slices.SortFunc(expected, func(a, b expectedIndex) int { return strings.Compare(a.name, b.name) })
+	return expected
+}
+
+// checkColumns 期待するカラムとテーブルのカラムを比較する
+func checkColumns(expected []expectedColumn, columns map[string]column) []Problem {
+	var problems []Problem
+	mapped := make(map[string]bool)
+	for _, e := range expected {
+		mapped[e.name] = true
+		c, ok := columns[e.name]
+		if !ok {
+			problems = append(problems, Problem{MissingColumn, e.name,
+				fmt.Sprintf("%sにあるカラムがテーブルにありません", e.source)})
+			continue
+		}
+
+		if category := categories[c.dataType]; e.category != "" && category != e.category {
+			problems = append(problems, Problem{TypeMismatch, e.name,
+				fmt.Sprintf("%sの型は%sですが、テーブルの型は%sです", e.source, e.category, c.dataType)})
+		}
+		if e.size != 0 && c.size != 0 && e.size != c.size {
+			problems = append(problems, Problem{TypeMismatch, e.name,
+				fmt.Sprintf("%sの長さは%dですが、テーブルの長さは%dです", e.source, e.size, c.size)})
+		}
+		if e.notNull && c.nullable {
+			problems = append(problems, Problem{TypeMismatch, e.name,
+				fmt.Sprintf("%sではNOT NULLですが、テーブルではNULLを許可しています", e.source)})
+		}
+		if e.nullable && !c.nullable {
+			problems = append(problems, Problem{TypeMismatch, e.name,
+				fmt.Sprintf("%sではNULLになりますが、テーブルではNOT NULLです", e.source)})
+		}
+	}
+
+	var unmapped []string
+	for name := range columns {
+		if !mapped[name] {
+			unmapped = append(unmapped, name)
+		}
+	}
+	slices.Sort(unmapped)
+	for _, name := range unmapped {
+		problems = append(problems, Problem{UnmappedColumn, name, "テーブルにあるカラムがどのモデルにもありません"})
+	}
+	return problems
+}
+
+// checkIndexes 期待するインデックスがあるか、他のインデックスで代わりになるインデックスがないかを調べる
+func checkIndexes(expected []expectedIndex, indexes []index) []Problem {
+	var problems []Problem
+	for _, e := range expected {
+		found := slices.ContainsFunc(indexes, func(idx index) bool {
+			return slices.Equal(idx.columns, e.columns) && (idx.unique || !e.unique)
+		})
+		if !found {
+			kind := "インデックス"
+			if e.unique {
+				kind = "ユニークインデックス"
+			}
+			problems = append(problems, Problem{MissingIndex, e.name,
+				fmt.Sprintf("gorm.Userにある(%s)の%sがテーブルにありません", strings.Join(e.columns, ", "), kind)})
+		}
+	}
+
+	for _, a := range indexes {
+		for _, b := range indexes {
+			if a.name == b.name || !covers(b, a) {
+				continue
+			}
+			// 同じ定義のインデックス同士は、主キーまたは名前順で先のものを残す
+			if covers(a, b) && (a.primary || (!b.primary && a.name < b.name)) {
+				continue
+			}
+			problems = append(problems, Problem{RedundantIndex, a.name,
+				fmt.Sprintf("(%s)のインデックスは%sで代わりになります", strings.Join(a.columns, ", "), b.name)})
+			break
+		}
+	}
+	return problems
+}
+
+// covers インデックスbがaの代わりになるかを返す
+// bの先頭のカラムがaのカラムと一致すれば検索に使える。ユニークインデックスは同じカラムのユニークインデックスでのみ代わりになる
+func covers(b, a index) bool {
+	if len(b.columns) < len(a.columns) || !slices.Equal(b.columns[:len(a.columns)], a.columns) {
+		return false
+	}
+	if a.unique {
+		return b.unique && len(b.columns) == len(a.columns)
+	}
+	return true
+}
+
+// readColumns information_schema.columnsからtableのカラムを読み取る
+// PostgreSQLのdata_typeはcitextなどの拡張機能の型をUSER-DEFINEDとするため、udt_nameを使う
+func readColumns(ctx context.Context, db *sql.DB, driver, table string) (map[string]column, error) {
+	query := `SELECT column_name, data_type, character_maximum_length, is_nullable
+		FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ?`
+	if driver == dbdriver.Postgres {
+		query = `SELECT column_name, udt_name, character_maximum_length, is_nullable
+			FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1`
+	}
+
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]column)
+	for rows.Next() {
+		var name, dataType, nullable string
+		var size sql.NullInt64
+		if err := rows.Scan(&name, &dataType, &size, &nullable); err != nil {
+			return nil, err
+		}
+		columns[name] = column{
+			dataType: strings.ToLower(dataType),
+			size:     int(size.Int64),
+			nullable: nullable == "YES",
+		}
+	}
+	return columns, rows.Err()
+}
+
+// readIndexes tableのインデックスを読み取る（カラムはインデックス内の順序で並べる）
+// MySQLはinformation_schema.statistics、PostgreSQLはinformation_schemaにインデックスがないためpg_catalogから読み取る
+func readIndexes(ctx context.Context, db *sql.DB, driver, table string) ([]index, error) {
+	query := `SELECT index_name, non_unique = 0, index_name = 'PRIMARY', column_name
+		FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ?
+		ORDER BY index_name, seq_in_index`
+	if driver == dbdriver.Postgres {
+		query = `SELECT i.relname, ix.indisunique, ix.indisprimary, a.attname
+			FROM pg_index ix
+			JOIN pg_class t ON t.oid = ix.indrelid
+			JOIN pg_class i ON i.oid = ix.indexrelid
+			JOIN pg_namespace n ON n.oid = t.relnamespace
+			CROSS JOIN generate_series(0, ix.indnkeyatts - 1) AS k(n)
+			JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = ix.indkey[k.n]
+			WHERE n.nspname = current_schema() AND t.relname = $1
+			ORDER BY i.relname, k.n`
+	}
+
+	rows, err := db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []index
+	for rows.Next() {
+		var name, columnName string
+		var unique, primary bool
+		if err := rows.Scan(&name, &unique, &primary, &columnName); err != nil {
+			return nil, err
+		}
+		if len(indexes) == 0 || indexes[len(indexes)-1].name != name {
+			indexes = append(indexes, index{name: name, unique: unique, primary: primary})
+		}
+		last := &indexes[len(indexes)-1]
+		last.columns = append(last.columns, columnName)
+	}
+	return indexes, rows.Err()
+}
@@ -0,0 +1,108 @@
+package schemadrift_test
+
+import (
+	"context"
+	"database/sql"
+	"go_sql_library/dbdriver"
+	"go_sql_library/dbtest"
+	"go_sql_library/schemadrift"
+	"slices"
+	"testing"
+)
+
+// setupTestDB マイグレーションを適用したテスト専用のデータベースに接続する
+// SQLiteにはinformation_schemaがないため、テストをスキップする
+func setupTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	if dbdriver.FromEnv() == dbdriver.SQLite {
+		t.Skip("SQLiteはスキーマドリフトの検出に対応していません")
+	}
+	return dbtest.Open(t)
+}
+
+func TestCheck_NoDrift(t *testing.T) {
+	t.Parallel()
+	db := setupTestDB(t)
+
+	problems, err := schemadrift.Check(context.Background(), db)
+	if err != nil {
+		t.Fatalf("Check エラー: %v", err)
+	}
+	// マイグレーションのテーブル定義はモデルと一致する
+	for _, p := range problems {
+		t.Errorf("想定外のずれ: %s", p)
+	}
+}
+
+func TestCheck_Drift(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	alters := []string{
+		"ALTER TABLE users DROP COLUMN version",
+		"ALTER TABLE users ADD COLUMN nickname VARCHAR(50)",
+		// emailのユニークインデックスと同じカラムのインデックス（マイグレーションで削除したidx_email）
+		"CREATE INDEX idx_email ON users(email)",
+	}
+	if dbdriver.Name(db) == dbdriver.Postgres {
+		alters = append(alters, "ALTER TABLE users ALTER COLUMN name TYPE VARCHAR(50)")
+	} else {
+		alters = append(alters, "ALTER TABLE users MODIFY name VARCHAR(50) NOT NULL")
+	}
+	for _, query := range alters {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatalf("%s の実行エラー: %v", query, err)
+		}
+	}
+
+	problems, err := schemadrift.Check(ctx, db)
+	if err != nil {
+		t.Fatalf("Check エラー: %v", err)
+	}
+
+	want := []struct {
+		kind   schemadrift.Kind
+		target string
+	}{
+		{schemadrift.MissingColumn, "version"},
+		{schemadrift.UnmappedColumn, "nickname"},
+		{schemadrift.TypeMismatch, "name"},
+		{schemadrift.RedundantIndex, "idx_email"},
+	}
+	for _, w := range want {
+		found := slices.ContainsFunc(problems, func(p schemadrift.Problem) bool {
+			return p.Kind == w.kind && p.Target == w.target
+		})
+		if !found {
+			t.Errorf("[%s] %s が検出されませんでした（検出したずれ: %v）", w.kind, w.target, problems)
+		}
+	}
+}
+
+func TestCheck_MissingIndex(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	// ユニーク制約のないテーブルに作り直す
+	create := "CREATE TABLE users (id INT AUTO_INCREMENT PRIMARY KEY, name VARCHAR(100) NOT NULL, email VARCHAR(100) NOT NULL, " +
+		"version INT NOT NULL DEFAULT 1, created_at TIMESTAMP NULL, updated_at TIMESTAMP NULL, deleted_at TIMESTAMP NULL)"
+	if dbdriver.Name(db) == dbdriver.Postgres {
+		create = "CREATE TABLE users (id SERIAL PRIMARY KEY, name VARCHAR(100) NOT NULL, email VARCHAR(100) NOT NULL, " +
+			"version INT NOT NULL DEFAULT 1, created_at TIMESTAMPTZ, updated_at TIMESTAMPTZ, deleted_at TIMESTAMPTZ)"
+	}
+	for _, query := range []string{"DROP TABLE users", create} {
+		if _, err := db.ExecContext(ctx, query); err != nil {
+			t.Fatalf("%s の実行エラー: %v", query, err)
+		}
+	}
+
+	problems, err := schemadrift.Check(ctx, db)
+	if err != nil {
+		t.Fatalf("Check エラー: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Kind != schemadrift.MissingIndex {
+		t.Errorf("期待するずれ: emailのユニークインデックスがない, 実際: %v", problems)
+	}
+}
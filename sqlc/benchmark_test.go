@@ -0,0 +1,26 @@
+//go:build benchmark
+// +build benchmark
+
+package sqlc
+
+import (
+	"go_sql_library/dbtest"
+	"go_sql_library/model"
+	"go_sql_library/repositorybench"
+	"os"
+	"testing"
+)
+
+// BenchmarkRepository 共通のベンチマーク（repositorybench）を実行する
+// MySQLのみに対応しているため、DB_DRIVERにかかわらずMySQLに専用のデータベースを作って計測する
+// 他の実装と同じエンジンで比較するため、DB_HOSTでMySQLサーバーを指定しない場合は省略する
+func BenchmarkRepository(b *testing.B) {
+	if os.Getenv("DB_HOST") == "" {
+		b.Skip("DB_HOSTが未指定のため省略します（プロセス内のMySQL互換サーバーでは他の実装と比較できない）")
+	}
+	repositorybench.Run(b, func(b *testing.B) model.UserRepository {
+		db := dbtest.OpenMySQL(b)
+		repositorybench.ConfigurePool(db)
+		return NewUserRepository(db)
+	})
+}
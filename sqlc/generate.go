@@ -0,0 +1,3 @@
+package sqlc
+
+//go:generate sqlc generate
@@ -0,0 +1,65 @@
+-- name: GetAllUsers :many
+-- 削除されていない全ユーザーを取得
+SELECT * FROM users
+WHERE deleted_at IS NULL
+ORDER BY id;
+
+-- name: ListUsers :many
+-- 検索条件に一致するユーザーをIDの昇順で取得
+-- sqlcは条件を動的に組み立てられないため、指定されなかった条件は常に真になるように書く
+SELECT * FROM users
+WHERE id > sqlc.arg(after_id)
+  AND (sqlc.arg(include_deleted) = TRUE OR deleted_at IS NULL)
+  AND (sqlc.arg(name_pattern) = '' OR name LIKE sqlc.arg(name_pattern) ESCAPE '!')
+  AND (sqlc.arg(email_pattern) = '' OR email LIKE sqlc.arg(email_pattern) ESCAPE '!')
+  AND (sqlc.narg(created_from) IS NULL OR created_at >= sqlc.narg(created_from))
+  AND (sqlc.narg(created_to) IS NULL OR created_at < sqlc.narg(created_to))
+ORDER BY id
+LIMIT ?;
+
+-- name: GetUser :one
+-- IDで削除されていないユーザーを取得
+SELECT * FROM users
+WHERE id = ? AND deleted_at IS NULL;
+
+-- name: GetUserByEmail :one
+-- メールアドレスで削除されていないユーザーを取得
+SELECT * FROM users
+WHERE email = ? AND deleted_at IS NULL;
+
+-- name: CreateUser :execlastid
+-- 新規ユーザーを作成
+INSERT INTO users (name, email) VALUES (?, ?);
+
+-- name: UpsertUser :execrows
+-- メールアドレスをキーにユーザーを作成または名前を更新し、論理削除済みなら復元
+INSERT INTO users (name, email) VALUES (?, ?)
+ON DUPLICATE KEY UPDATE name = VALUES(name), deleted_at = NULL, version = version + 1;
+
+-- name: UpdateUser :execrows
+-- 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+UPDATE users SET name = ?, email = ?, version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL;
+
+-- name: PatchUser :execrows
+-- 指定したフィールドだけをバージョンが一致する場合のみ更新（NULLのフィールドは変更しない）
+UPDATE users
+SET name = COALESCE(sqlc.narg(name), name),
+    email = COALESCE(sqlc.narg(email), email),
+    version = version + 1
+WHERE id = sqlc.arg(id) AND version = sqlc.arg(version) AND deleted_at IS NULL;
+
+-- name: DeleteUser :execrows
+-- ユーザーをバージョンが一致する場合のみ論理削除
+UPDATE users SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = ? AND version = ? AND deleted_at IS NULL;
+
+-- name: RestoreUser :execrows
+-- 論理削除したユーザーを復元
+UPDATE users SET deleted_at = NULL, version = version + 1
+WHERE id = ? AND deleted_at IS NOT NULL;
+
+-- name: PurgeUsers :execrows
+-- 指定日時より前に論理削除したユーザーを物理削除
+DELETE FROM users
+WHERE deleted_at < ?;
@@ -0,0 +1,297 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.31.1
+// source: query.sql
+
+package sqlc
+
+import (
+	"context"
+	"database/sql"
+)
+
+const createUser = `-- name: CreateUser :execlastid
+INSERT INTO users (name, email) VALUES (?, ?)
+`
+
+type CreateUserParams struct {
+	Name  string
+	Email string
+}
+
+// 新規ユーザーを作成
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createUser, arg.Name, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+const deleteUser = `-- name: DeleteUser :execrows
+UPDATE users SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = ? AND version = ? AND deleted_at IS NULL
+`
+
+type DeleteUserParams struct {
+	ID      int32
+	Version int32
+}
+
+// ユーザーをバージョンが一致する場合のみ論理削除
+func (q *Queries) DeleteUser(ctx context.Context, arg DeleteUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, arg.ID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getAllUsers = `-- name: GetAllUsers :many
+SELECT id, name, email, version, created_at, updated_at, deleted_at FROM users
+WHERE deleted_at IS NULL
+ORDER BY id
+`
+
+// 削除されていない全ユーザーを取得
+func (q *Queries) GetAllUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, getAllUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Version,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUser = `-- name: GetUser :one
+SELECT id, name, email, version, created_at, updated_at, deleted_at FROM users
+WHERE id = ? AND deleted_at IS NULL
+`
+
+// IDで削除されていないユーザーを取得
+func (q *Queries) GetUser(ctx context.Context, id int32) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Version,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, name, email, version, created_at, updated_at, deleted_at FROM users
+WHERE email = ? AND deleted_at IS NULL
+`
+
+// メールアドレスで削除されていないユーザーを取得
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Email,
+		&i.Version,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+	)
+	return i, err
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, name, email, version, created_at, updated_at, deleted_at FROM users
+WHERE id > ?
+  AND (? = TRUE OR deleted_at IS NULL)
+  AND (? = '' OR name LIKE ? ESCAPE '!')
+  AND (? = '' OR email LIKE ? ESCAPE '!')
+  AND (? IS NULL OR created_at >= ?)
+  AND (? IS NULL OR created_at < ?)
+ORDER BY id
+LIMIT ?
+`
+
+type ListUsersParams struct {
+	AfterID        int32
+	IncludeDeleted interface{}
+	NamePattern    string
+	EmailPattern   string
+	CreatedFrom    sql.NullTime
+	CreatedTo      sql.NullTime
+	Limit          int32
+}
+
+// 検索条件に一致するユーザーをIDの昇順で取得
+// sqlcは条件を動的に組み立てられないため、指定されなかった条件は常に真になるように書く
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers,
+		arg.AfterID,
+		arg.IncludeDeleted,
+		arg.NamePattern,
+		arg.NamePattern,
+		arg.EmailPattern,
+		arg.EmailPattern,
+		arg.CreatedFrom,
+		arg.CreatedFrom,
+		arg.CreatedTo,
+		arg.CreatedTo,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Email,
+			&i.Version,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const patchUser = `-- name: PatchUser :execrows
+UPDATE users
+SET name = COALESCE(?, name),
+    email = COALESCE(?, email),
+    version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL
+`
+
+type PatchUserParams struct {
+	Name    sql.NullString
+	Email   sql.NullString
+	ID      int32
+	Version int32
+}
+
+// 指定したフィールドだけをバージョンが一致する場合のみ更新（NULLのフィールドは変更しない）
+func (q *Queries) PatchUser(ctx context.Context, arg PatchUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, patchUser,
+		arg.Name,
+		arg.Email,
+		arg.ID,
+		arg.Version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const purgeUsers = `-- name: PurgeUsers :execrows
+DELETE FROM users
+WHERE deleted_at < ?
+`
+
+// 指定日時より前に論理削除したユーザーを物理削除
+func (q *Queries) PurgeUsers(ctx context.Context, deletedAt sql.NullTime) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeUsers, deletedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUser = `-- name: RestoreUser :execrows
+UPDATE users SET deleted_at = NULL, version = version + 1
+WHERE id = ? AND deleted_at IS NOT NULL
+`
+
+// 論理削除したユーザーを復元
+func (q *Queries) RestoreUser(ctx context.Context, id int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, restoreUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateUser = `-- name: UpdateUser :execrows
+UPDATE users SET name = ?, email = ?, version = version + 1
+WHERE id = ? AND version = ? AND deleted_at IS NULL
+`
+
+type UpdateUserParams struct {
+	Name    string
+	Email   string
+	ID      int32
+	Version int32
+}
+
+// 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateUser,
+		arg.Name,
+		arg.Email,
+		arg.ID,
+		arg.Version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertUser = `-- name: UpsertUser :execrows
+INSERT INTO users (name, email) VALUES (?, ?)
+ON DUPLICATE KEY UPDATE name = VALUES(name), deleted_at = NULL, version = version + 1
+`
+
+type UpsertUserParams struct {
+	Name  string
+	Email string
+}
+
+// メールアドレスをキーにユーザーを作成または名前を更新し、論理削除済みなら復元
+func (q *Queries) UpsertUser(ctx context.Context, arg UpsertUserParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upsertUser, arg.Name, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
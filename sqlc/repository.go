@@ -0,0 +1,302 @@
+package sqlc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go_sql_library/dberr"
+	"go_sql_library/model"
+	"strings"
+	"time"
+)
+
+// createManyBatchSize CreateManyで1つのINSERT文にまとめる最大件数
+const createManyBatchSize = 1000
+
+// UserRepository sqlcで生成したクエリを使ったユーザーリポジトリ
+// クエリはquery.sql、テーブル定義はmigrate/migrations/mysqlのマイグレーションから生成している（*.gen.goは編集しない）
+type UserRepository struct {
+	db *sql.DB
+
+	// q 生成したクエリの実行先（WithTx内では*sql.Txに紐づく）
+	q *Queries
+
+	// inTx WithTx内のリポジトリかどうか
+	inTx bool
+}
+
+// NewUserRepository リポジトリの初期化
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db, q: New(db)}
+}
+
+// toModelUser 生成したUserをmodelのUserに変換
+// sqlcはNULLを許容するカラムをsql.NullTimeで生成するため、ここで変換する
+func toModelUser(u User) model.User {
+	user := model.User{
+		ID:        int(u.ID),
+		Name:      u.Name,
+		Email:     u.Email,
+		Version:   int(u.Version),
+		CreatedAt: u.CreatedAt.Time,
+		UpdatedAt: u.UpdatedAt.Time,
+	}
+	if u.DeletedAt.Valid {
+		deletedAt := u.DeletedAt.Time
+		user.DeletedAt = &deletedAt
+	}
+	return user
+}
+
+// toModelUsers 生成したUserのスライスをmodel.Userのスライスに変換
+func toModelUsers(rows []User) []model.User {
+	users := make([]model.User, len(rows))
+	for i, u := range rows {
+		users[i] = toModelUser(u)
+	}
+	return users
+}
+
+// nullTime ゼロ値を未指定（NULL）として扱うsql.NullTimeを作る
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// GetAll 削除されていない全ユーザーを取得
+func (r *UserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	rows, err := r.q.GetAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return toModelUsers(rows), nil
+}
+
+// List filterに一致するユーザーをIDの昇順でpage.AfterIDより後ろから最大page.Limit件取得
+// 条件はquery.sqlに固定で書かれており、空文字やNULLを渡した条件は無視される
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter, page model.Page) ([]model.User, error) {
+	params := ListUsersParams{
+		AfterID:        int32(page.AfterID),
+		IncludeDeleted: filter.IncludeDeleted,
+		CreatedFrom:    nullTime(filter.CreatedFrom),
+		CreatedTo:      nullTime(filter.CreatedTo),
+		Limit:          int32(page.Limit),
+	}
+	if filter.NameContains != "" {
+		params.NamePattern = "%" + model.EscapeLike(filter.NameContains) + "%"
+	}
+	if filter.EmailDomain != "" {
+		params.EmailPattern = "%@" + model.EscapeLike(filter.EmailDomain)
+	}
+
+	rows, err := r.q.ListUsers(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return toModelUsers(rows), nil
+}
+
+// GetByID IDで削除されていないユーザーを取得
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	u, err := r.q.GetUser(ctx, int32(id))
+	if err != nil {
+		return nil, dberr.Translate(err)
+	}
+	user := toModelUser(u)
+	return &user, nil
+}
+
+// Create 新規ユーザーを作成
+func (r *UserRepository) Create(ctx context.Context, name, email string) (*model.User, error) {
+	id, err := r.q.CreateUser(ctx, CreateUserParams{Name: name, Email: email})
+	if err != nil {
+		return nil, dberr.Translate(err)
+	}
+	return r.GetByID(ctx, int(id))
+}
+
+// CreateMany 複数のユーザーを複数行INSERTでまとめて作成
+// createManyBatchSize件ごとに1つのINSERT文を発行し、全体を1つのトランザクションで実行する
+func (r *UserRepository) CreateMany(ctx context.Context, users []model.NewUser) ([]int, error) {
+	ids := make([]int, 0, len(users))
+	err := r.WithTx(ctx, func(repo model.UserRepository) error {
+		tx := repo.(*UserRepository)
+		for start := 0; start < len(users); start += createManyBatchSize {
+			end := min(start+createManyBatchSize, len(users))
+			chunkIDs, err := tx.insertChunk(ctx, users[start:end])
+			if err != nil {
+				return err
+			}
+			ids = append(ids, chunkIDs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// insertChunk 1つの複数行INSERT文でユーザーを作成
+// sqlcは可変長のVALUES句を生成できない（MySQLの:copyfromはLOAD DATAを使うためサーバー設定が必要）ので、
+// 生成したクエリと同じ実行先（DBTX）でVALUES句を組み立てて実行する
+// 複数行INSERTで採番されるIDは連続するため、先頭のIDから各行のIDを求める
+func (r *UserRepository) insertChunk(ctx context.Context, users []model.NewUser) ([]int, error) {
+	placeholders := make([]string, len(users))
+	args := make([]any, 0, len(users)*2)
+	for i, u := range users {
+		placeholders[i] = "(?, ?)"
+		args = append(args, u.Name, u.Email)
+	}
+
+	query := "INSERT INTO users (name, email) VALUES " + strings.Join(placeholders, ", ")
+	result, err := r.q.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, dberr.Translate(err)
+	}
+
+	firstID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(users))
+	for i := range ids {
+		ids[i] = int(firstID) + i
+	}
+	return ids, nil
+}
+
+// Upsert メールアドレスをキーにユーザーを作成または名前を更新
+// ON DUPLICATE KEY UPDATEの影響行数で挿入か更新かを判定する。論理削除済みのユーザーは復元する
+func (r *UserRepository) Upsert(ctx context.Context, name, email string) (*model.User, bool, error) {
+	// 挿入時は1、既存行の更新時は2になる
+	affected, err := r.q.UpsertUser(ctx, UpsertUserParams{Name: name, Email: email})
+	if err != nil {
+		return nil, false, dberr.Translate(err)
+	}
+
+	u, err := r.q.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, false, dberr.Translate(err)
+	}
+	user := toModelUser(u)
+	return &user, affected == 1, nil
+}
+
+// Update 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+func (r *UserRepository) Update(ctx context.Context, id int, name, email string, version int) error {
+	affected, err := r.q.UpdateUser(ctx, UpdateUserParams{
+		Name:    name,
+		Email:   email,
+		ID:      int32(id),
+		Version: int32(version),
+	})
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, affected, id)
+}
+
+// Patch 指定したフィールドだけをバージョンが一致する場合のみ更新
+// 指定しなかったフィールドはNULLを渡し、クエリ側のCOALESCEで現在の値のままにする
+func (r *UserRepository) Patch(ctx context.Context, id int, patch model.UserPatch, version int) error {
+	params := PatchUserParams{
+		ID:      int32(id),
+		Version: int32(version),
+	}
+	if patch.Name != nil {
+		params.Name = sql.NullString{String: *patch.Name, Valid: true}
+	}
+	if patch.Email != nil {
+		params.Email = sql.NullString{String: *patch.Email, Valid: true}
+	}
+
+	affected, err := r.q.PatchUser(ctx, params)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, affected, id)
+}
+
+// Delete ユーザーをバージョンが一致する場合のみ論理削除
+func (r *UserRepository) Delete(ctx context.Context, id int, version int) error {
+	affected, err := r.q.DeleteUser(ctx, DeleteUserParams{ID: int32(id), Version: int32(version)})
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, affected, id)
+}
+
+// checkVersionMatched バージョン付きの更新で対象行がなかった場合にその原因をエラーとして返す
+// ユーザーが存在しなければErrNotFound、存在すればバージョン不一致としてErrVersionConflictを返す
+func (r *UserRepository) checkVersionMatched(ctx context.Context, affected int64, id int) error {
+	if affected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return model.ErrVersionConflict
+}
+
+// Restore 論理削除したユーザーを復元
+func (r *UserRepository) Restore(ctx context.Context, id int) error {
+	affected, err := r.q.RestoreUser(ctx, int32(id))
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// Purge olderThanより前に論理削除したユーザーを物理削除し、削除件数を返す
+func (r *UserRepository) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	purged, err := r.q.PurgeUsers(ctx, sql.NullTime{Time: olderThan.UTC(), Valid: true})
+	if err != nil {
+		return 0, dberr.Translate(err)
+	}
+	return purged, nil
+}
+
+// WithTx fnをトランザクション内で実行する
+// fnがエラーを返すかパニックした場合はロールバックし、それ以外はコミットする
+func (r *UserRepository) WithTx(ctx context.Context, fn func(repo model.UserRepository) error) error {
+	// 既にトランザクション内であれば同じトランザクションに参加する
+	if r.inTx {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&UserRepository{db: r.db, q: r.q.WithTx(tx), inTx: true}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close データベース接続を閉じる
+// トランザクション内のリポジトリは接続を所有しないため何もしない
+func (r *UserRepository) Close() error {
+	if r.inTx {
+		return nil
+	}
+	if r.db == nil {
+		return errors.New("database connection is nil")
+	}
+	return r.db.Close()
+}
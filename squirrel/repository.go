@@ -0,0 +1,366 @@
+package squirrel
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go_sql_library/dberr"
+	"go_sql_library/model"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// createManyBatchSize CreateManyで1つのINSERT文にまとめる最大件数
+const createManyBatchSize = 1000
+
+// userColumns ユーザーを取得するときのカラム
+var userColumns = []string{"id", "name", "email", "version", "created_at", "updated_at", "deleted_at"}
+
+// notDeleted 論理削除されていない行の条件（sq.Eqはnilの値をIS NULLに変換する）
+var notDeleted = sq.Eq{"deleted_at": nil}
+
+// UserRepository squirrelでSQLを組み立て、database/sqlで実行するユーザーリポジトリ
+type UserRepository struct {
+	db *sql.DB
+
+	// q クエリの実行先（WithTx内では*sql.Tx）
+	q dbtx
+}
+
+// dbtx *sql.DBと*sql.Txに共通するクエリ実行メソッド
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// NewUserRepository リポジトリの初期化
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db, q: db}
+}
+
+// selectUsers ユーザーを取得するSELECT文の共通部分
+func selectUsers() sq.SelectBuilder {
+	return sq.Select(userColumns...).From("users")
+}
+
+// GetAll 削除されていない全ユーザーを取得
+func (r *UserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	return r.queryUsers(ctx, selectUsers().Where(notDeleted).OrderBy("id"))
+}
+
+// List filterに一致するユーザーをIDの昇順でpage.AfterIDより後ろから最大page.Limit件取得
+// 指定された条件だけをWhereで追加し、ANDで結合するのはsquirrelに任せる
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter, page model.Page) ([]model.User, error) {
+	q := selectUsers().Where(sq.Gt{"id": page.AfterID})
+	if !filter.IncludeDeleted {
+		q = q.Where(notDeleted)
+	}
+	if filter.NameContains != "" {
+		q = q.Where("name LIKE ? ESCAPE '"+model.LikeEscapeChar+"'", "%"+model.EscapeLike(filter.NameContains)+"%")
+	}
+	if filter.EmailDomain != "" {
+		q = q.Where("email LIKE ? ESCAPE '"+model.LikeEscapeChar+"'", "%@"+model.EscapeLike(filter.EmailDomain))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		q = q.Where(sq.GtOrEq{"created_at": filter.CreatedFrom})
+	}
+	if !filter.CreatedTo.IsZero() {
+		q = q.Where(sq.Lt{"created_at": filter.CreatedTo})
+	}
+
+	return r.queryUsers(ctx, q.OrderBy("id").Limit(uint64(page.Limit)))
+}
+
+// queryUsers 組み立てたSELECT文を実行して複数行のユーザーを取得
+func (r *UserRepository) queryUsers(ctx context.Context, q sq.SelectBuilder) ([]model.User, error) {
+	query, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		var u model.User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
+// queryUser 組み立てたSELECT文を実行して1件のユーザーを取得
+func (r *UserRepository) queryUser(ctx context.Context, q sq.SelectBuilder) (*model.User, error) {
+	query, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var u model.User
+	if err := scanUser(r.q.QueryRowContext(ctx, query, args...), &u); err != nil {
+		return nil, dberr.Translate(err)
+	}
+	return &u, nil
+}
+
+// scanUser 1行分の結果をユーザーに読み込む
+func scanUser(row interface{ Scan(dest ...any) error }, u *model.User) error {
+	return row.Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
+}
+
+// exec 組み立てたINSERT・UPDATE・DELETE文を実行
+func (r *UserRepository) exec(ctx context.Context, q sq.Sqlizer) (sql.Result, error) {
+	query, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	return r.q.ExecContext(ctx, query, args...)
+}
+
+// GetByID IDで削除されていないユーザーを取得
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	return r.queryUser(ctx, selectUsers().Where(sq.Eq{"id": id}).Where(notDeleted))
+}
+
+// getByEmail メールアドレスで削除されていないユーザーを取得
+func (r *UserRepository) getByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.queryUser(ctx, selectUsers().Where(sq.Eq{"email": email}).Where(notDeleted))
+}
+
+// Create 新規ユーザーを作成
+func (r *UserRepository) Create(ctx context.Context, name, email string) (*model.User, error) {
+	result, err := r.exec(ctx, sq.Insert("users").Columns("name", "email").Values(name, email))
+	if err != nil {
+		return nil, dberr.Translate(err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, int(id))
+}
+
+// CreateMany 複数のユーザーを複数行INSERTでまとめて作成
+// createManyBatchSize件ごとに1つのINSERT文を発行し、全体を1つのトランザクションで実行する
+func (r *UserRepository) CreateMany(ctx context.Context, users []model.NewUser) ([]int, error) {
+	ids := make([]int, 0, len(users))
+	err := r.WithTx(ctx, func(repo model.UserRepository) error {
+		tx := repo.(*UserRepository)
+		for start := 0; start < len(users); start += createManyBatchSize {
+			end := min(start+createManyBatchSize, len(users))
+			chunkIDs, err := tx.insertChunk(ctx, users[start:end])
+			if err != nil {
+				return err
+			}
+			ids = append(ids, chunkIDs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// insertChunk 1つの複数行INSERT文でユーザーを作成
+// 複数行INSERTで採番されるIDは連続するため、先頭のIDから各行のIDを求める
+func (r *UserRepository) insertChunk(ctx context.Context, users []model.NewUser) ([]int, error) {
+	q := sq.Insert("users").Columns("name", "email")
+	for _, u := range users {
+		q = q.Values(u.Name, u.Email)
+	}
+
+	result, err := r.exec(ctx, q)
+	if err != nil {
+		return nil, dberr.Translate(err)
+	}
+
+	firstID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, len(users))
+	for i := range ids {
+		ids[i] = int(firstID) + i
+	}
+	return ids, nil
+}
+
+// Upsert メールアドレスをキーにユーザーを作成または名前を更新
+// ON DUPLICATE KEY UPDATEの影響行数で挿入か更新かを判定する。論理削除済みのユーザーは復元する
+func (r *UserRepository) Upsert(ctx context.Context, name, email string) (*model.User, bool, error) {
+	q := sq.Insert("users").Columns("name", "email").Values(name, email).
+		Suffix("ON DUPLICATE KEY UPDATE name = VALUES(name), deleted_at = NULL, version = version + 1")
+	result, err := r.exec(ctx, q)
+	if err != nil {
+		return nil, false, dberr.Translate(err)
+	}
+
+	// 挿入時は1、既存行の更新時は2になる
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+
+	user, err := r.getByEmail(ctx, email)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, affected == 1, nil
+}
+
+// updateVersioned 削除されていないユーザーをバージョンが一致する場合のみ更新するUPDATE文の共通部分
+func updateVersioned(id, version int) sq.UpdateBuilder {
+	return sq.Update("users").
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": id, "version": version}).
+		Where(notDeleted)
+}
+
+// Update 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+func (r *UserRepository) Update(ctx context.Context, id int, name, email string, version int) error {
+	q := updateVersioned(id, version).
+		Set("name", name).
+		Set("email", email)
+	result, err := r.exec(ctx, q)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// Patch 指定したフィールドだけをバージョンが一致する場合のみ更新
+func (r *UserRepository) Patch(ctx context.Context, id int, patch model.UserPatch, version int) error {
+	q := updateVersioned(id, version)
+	if patch.Name != nil {
+		q = q.Set("name", *patch.Name)
+	}
+	if patch.Email != nil {
+		q = q.Set("email", *patch.Email)
+	}
+
+	result, err := r.exec(ctx, q)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// Delete ユーザーをバージョンが一致する場合のみ論理削除
+func (r *UserRepository) Delete(ctx context.Context, id int, version int) error {
+	q := sq.Update("users").
+		Set("deleted_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where(sq.Eq{"id": id, "version": version}).
+		Where(notDeleted)
+	result, err := r.exec(ctx, q)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// checkVersionMatched バージョン付きの更新で対象行がなかった場合にその原因をエラーとして返す
+// ユーザーが存在しなければErrNotFound、存在すればバージョン不一致としてErrVersionConflictを返す
+func (r *UserRepository) checkVersionMatched(ctx context.Context, result sql.Result, id int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return model.ErrVersionConflict
+}
+
+// Restore 論理削除したユーザーを復元
+func (r *UserRepository) Restore(ctx context.Context, id int) error {
+	q := sq.Update("users").
+		Set("deleted_at", nil).
+		Set("version", sq.Expr("version + 1")).
+		Where(sq.Eq{"id": id}).
+		Where(sq.NotEq{"deleted_at": nil})
+	result, err := r.exec(ctx, q)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// Purge olderThanより前に論理削除したユーザーを物理削除し、削除件数を返す
+func (r *UserRepository) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	result, err := r.exec(ctx, sq.Delete("users").Where(sq.Lt{"deleted_at": olderThan}))
+	if err != nil {
+		return 0, dberr.Translate(err)
+	}
+	return result.RowsAffected()
+}
+
+// WithTx fnをトランザクション内で実行する
+// fnがエラーを返すかパニックした場合はロールバックし、それ以外はコミットする
+func (r *UserRepository) WithTx(ctx context.Context, fn func(repo model.UserRepository) error) error {
+	// 既にトランザクション内であれば同じトランザクションに参加する
+	if r.inTx() {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&UserRepository{db: r.db, q: tx}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// inTx トランザクション内のリポジトリかどうか
+func (r *UserRepository) inTx() bool {
+	_, ok := r.q.(*sql.Tx)
+	return ok
+}
+
+// Close データベース接続を閉じる
+// トランザクション内のリポジトリは接続を所有しないため何もしない
+func (r *UserRepository) Close() error {
+	if r.inTx() {
+		return nil
+	}
+	if r.db == nil {
+		return errors.New("database connection is nil")
+	}
+	return r.db.Close()
+}
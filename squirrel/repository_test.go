@@ -0,0 +1,21 @@
+package squirrel
+
+import (
+	"database/sql"
+	"go_sql_library/dbtest"
+	"go_sql_library/model"
+	"go_sql_library/repositorytest"
+	"testing"
+)
+
+// setupTestDB MySQLにテスト専用のデータベースを作って接続する（テスト終了時に削除される）
+func setupTestDB(t *testing.T) *sql.DB {
+	return dbtest.OpenMySQL(t)
+}
+
+func TestConformance(t *testing.T) {
+	t.Parallel()
+	repositorytest.RunConformance(t, func(t *testing.T) model.UserRepository {
+		return NewUserRepository(setupTestDB(t))
+	})
+}
@@ -1,24 +1,98 @@
 package standard
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"go_sql_library/dbdriver"
+	"go_sql_library/dberr"
 	"go_sql_library/model"
+	"strings"
+	"time"
 )
 
+// createManyBatchSize CreateManyで1つのINSERT文にまとめる最大件数
+const createManyBatchSize = 1000
+
+// selectUsers ユーザーを取得するSELECT文の共通部分
+const selectUsers = "SELECT id, name, email, version, created_at, updated_at, deleted_at FROM users"
+
 // UserRepository 標準database/sqlを使ったユーザーリポジトリ
 type UserRepository struct {
 	db *sql.DB
+
+	// q クエリの実行先（WithTx内では*sql.Tx）
+	q dbtx
+
+	// driver 接続先のデータベース（dbdriver.MySQL、dbdriver.SQLiteまたはdbdriver.Postgres）
+	driver string
+}
+
+// dbtx *sql.DBと*sql.Txに共通するクエリ実行メソッド
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 // NewUserRepository リポジトリの初期化
 func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+	return &UserRepository{db: db, q: db, driver: dbdriver.Name(db)}
+}
+
+// rebind ?で書いたクエリを接続先のプレースホルダの形式に変換する
+func (r *UserRepository) rebind(query string) string {
+	return dbdriver.Rebind(r.driver, query)
 }
 
-// GetAll 全ユーザーを取得
-func (r *UserRepository) GetAll() ([]model.User, error) {
-	query := "SELECT id, name, email, created_at, updated_at FROM users ORDER BY id"
-	rows, err := r.db.Query(query)
+// GetAll 削除されていない全ユーザーを取得
+func (r *UserRepository) GetAll(ctx context.Context) ([]model.User, error) {
+	query := selectUsers + " WHERE deleted_at IS NULL ORDER BY id"
+	return r.queryUsers(ctx, query)
+}
+
+// List filterに一致するユーザーをIDの昇順でpage.AfterIDより後ろから最大page.Limit件取得
+func (r *UserRepository) List(ctx context.Context, filter model.UserFilter, page model.Page) ([]model.User, error) {
+	where, args := buildWhere(filter, page)
+	query := selectUsers + " WHERE " + where + " ORDER BY id LIMIT ?"
+	args = append(args, page.Limit)
+	return r.queryUsers(ctx, query, args...)
+}
+
+// buildWhere 検索条件からWHERE句とバインドするパラメータを組み立てる
+// ユーザーが指定した値はすべてプレースホルダで渡す
+// SQLiteは日時を文字列として比較するため、日時は保存している値と同じくUTCで渡す
+func buildWhere(filter model.UserFilter, page model.Page) (string, []any) {
+	conds := []string{"id > ?"}
+	args := []any{page.AfterID}
+
+	if !filter.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
+
+	if filter.NameContains != "" {
+		conds = append(conds, "name LIKE ? ESCAPE '"+model.LikeEscapeChar+"'")
+		args = append(args, "%"+model.EscapeLike(filter.NameContains)+"%")
+	}
+	if filter.EmailDomain != "" {
+		conds = append(conds, "email LIKE ? ESCAPE '"+model.LikeEscapeChar+"'")
+		args = append(args, "%@"+model.EscapeLike(filter.EmailDomain))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, filter.CreatedFrom.UTC())
+	}
+	if !filter.CreatedTo.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, filter.CreatedTo.UTC())
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// queryUsers 複数行のユーザーを取得するクエリを実行
+func (r *UserRepository) queryUsers(ctx context.Context, query string, args ...any) ([]model.User, error) {
+	rows, err := r.q.QueryContext(ctx, r.rebind(query), args...)
 	if err != nil {
 		return nil, err
 	}
@@ -27,8 +101,7 @@ func (r *UserRepository) GetAll() ([]model.User, error) {
 	var users []model.User
 	for rows.Next() {
 		var u model.User
-		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt)
-		if err != nil {
+		if err := scanUser(rows, &u); err != nil {
 			return nil, err
 		}
 		users = append(users, u)
@@ -37,48 +110,313 @@ func (r *UserRepository) GetAll() ([]model.User, error) {
 	return users, rows.Err()
 }
 
-// GetByID IDでユーザーを取得
-func (r *UserRepository) GetByID(id int) (*model.User, error) {
-	query := "SELECT id, name, email, created_at, updated_at FROM users WHERE id = ?"
+// scanUser 1行分の結果をユーザーに読み込む
+func scanUser(row interface{ Scan(dest ...any) error }, u *model.User) error {
+	return row.Scan(&u.ID, &u.Name, &u.Email, &u.Version, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt)
+}
+
+// GetByID IDで削除されていないユーザーを取得
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	query := selectUsers + " WHERE id = ? AND deleted_at IS NULL"
 	var u model.User
-	err := r.db.QueryRow(query, id).Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt)
+	err := scanUser(r.q.QueryRowContext(ctx, r.rebind(query), id), &u)
 	if err != nil {
-		return nil, err
+		return nil, dberr.Translate(err)
+	}
+	return &u, nil
+}
+
+// getByEmail メールアドレスで削除されていないユーザーを取得
+func (r *UserRepository) getByEmail(ctx context.Context, email string) (*model.User, error) {
+	query := selectUsers + " WHERE email = ? AND deleted_at IS NULL"
+	var u model.User
+	err := scanUser(r.q.QueryRowContext(ctx, r.rebind(query), email), &u)
+	if err != nil {
+		return nil, dberr.Translate(err)
 	}
 	return &u, nil
 }
 
 // Create 新規ユーザーを作成
-func (r *UserRepository) Create(name, email string) (*model.User, error) {
+func (r *UserRepository) Create(ctx context.Context, name, email string) (*model.User, error) {
 	query := "INSERT INTO users (name, email) VALUES (?, ?)"
-	result, err := r.db.Exec(query, name, email)
+	ids, err := r.insert(ctx, query, 1, name, email)
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	return r.GetByID(ctx, ids[0])
+}
+
+// CreateMany 複数のユーザーを複数行INSERTでまとめて作成
+// createManyBatchSize件ごとに1つのINSERT文を発行し、全体を1つのトランザクションで実行する
+func (r *UserRepository) CreateMany(ctx context.Context, users []model.NewUser) ([]int, error) {
+	ids := make([]int, 0, len(users))
+	err := r.WithTx(ctx, func(repo model.UserRepository) error {
+		tx := repo.(*UserRepository)
+		for start := 0; start < len(users); start += createManyBatchSize {
+			end := min(start+createManyBatchSize, len(users))
+			chunkIDs, err := tx.insertChunk(ctx, users[start:end])
+			if err != nil {
+				return err
+			}
+			ids = append(ids, chunkIDs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// insertChunk 1つの複数行INSERT文でユーザーを作成
+func (r *UserRepository) insertChunk(ctx context.Context, users []model.NewUser) ([]int, error) {
+	placeholders := make([]string, len(users))
+	args := make([]any, 0, len(users)*2)
+	for i, u := range users {
+		placeholders[i] = "(?, ?)"
+		args = append(args, u.Name, u.Email)
+	}
+
+	query := "INSERT INTO users (name, email) VALUES " + strings.Join(placeholders, ", ")
+	return r.insert(ctx, query, len(users), args...)
+}
+
+// insert n行を挿入するINSERT文を実行し、作成した行のIDを挿入した順に返す
+// PostgreSQLはLastInsertIdに対応していないため、RETURNING idで受け取る
+func (r *UserRepository) insert(ctx context.Context, query string, n int, args ...any) ([]int, error) {
+	if r.driver == dbdriver.Postgres {
+		return r.insertReturning(ctx, query, n, args...)
+	}
+
+	result, err := r.q.ExecContext(ctx, r.rebind(query), args...)
+	if err != nil {
+		return nil, dberr.Translate(err)
+	}
+
+	// 複数行INSERTで採番されるIDは連続するため、先頭のIDから各行のIDを求める
+	// LastInsertIdはMySQLでは先頭の行、SQLiteでは最後の行のIDになる
+	firstID, err := result.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
+	if r.driver == dbdriver.SQLite {
+		firstID -= int64(n - 1)
+	}
+
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = int(firstID) + i
+	}
+	return ids, nil
+}
+
+// insertReturning INSERT文にRETURNING idを付けて実行し、返された行のIDを読み込む
+func (r *UserRepository) insertReturning(ctx context.Context, query string, n int, args ...any) ([]int, error) {
+	rows, err := r.q.QueryContext(ctx, r.rebind(query+" RETURNING id"), args...)
+	if err != nil {
+		return nil, dberr.Translate(err)
+	}
+	defer rows.Close()
+
+	ids := make([]int, 0, n)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, dberr.Translate(err)
+	}
+	return ids, nil
+}
+
+// Upsert メールアドレスをキーにユーザーを作成または名前を更新
+// 論理削除済みのユーザーは復元する
+func (r *UserRepository) Upsert(ctx context.Context, name, email string) (*model.User, bool, error) {
+	var inserted bool
+	var err error
+	if r.driver == dbdriver.MySQL {
+		inserted, err = r.upsertMySQL(ctx, name, email)
+	} else {
+		inserted, err = r.upsertOnConflict(ctx, name, email)
+	}
+	if err != nil {
+		return nil, false, err
+	}
 
-	return r.GetByID(int(id))
+	user, err := r.getByEmail(ctx, email)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, inserted, nil
 }
 
-// Update ユーザー情報を更新
-func (r *UserRepository) Update(id int, name, email string) error {
-	query := "UPDATE users SET name = ?, email = ? WHERE id = ?"
-	_, err := r.db.Exec(query, name, email, id)
-	return err
+// upsertMySQL ON DUPLICATE KEY UPDATEで作成または更新し、影響行数で挿入か更新かを判定する
+func (r *UserRepository) upsertMySQL(ctx context.Context, name, email string) (bool, error) {
+	query := "INSERT INTO users (name, email) VALUES (?, ?) " +
+		"ON DUPLICATE KEY UPDATE name = VALUES(name), deleted_at = NULL, version = version + 1"
+	result, err := r.q.ExecContext(ctx, query, name, email)
+	if err != nil {
+		return false, dberr.Translate(err)
+	}
+
+	// 挿入時は1、既存行の更新時は2になる
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
 }
 
-// Delete ユーザーを削除
-func (r *UserRepository) Delete(id int) error {
-	query := "DELETE FROM users WHERE id = ?"
-	_, err := r.db.Exec(query, id)
-	return err
+// upsertOnConflict SQLiteとPostgreSQLのON CONFLICTで作成または更新し、RETURNINGで返したバージョンで挿入か更新かを判定する
+// 影響行数は挿入でも更新でも1になるため使えない
+// 既存行の更新では必ずversionを増やすため、versionが1なら挿入された行になる
+// PostgreSQLではexcludedのversionと区別できないため、既存行のversionはテーブル名で指定する
+func (r *UserRepository) upsertOnConflict(ctx context.Context, name, email string) (bool, error) {
+	query := "INSERT INTO users (name, email) VALUES (?, ?) " +
+		"ON CONFLICT (email) DO UPDATE SET name = excluded.name, deleted_at = NULL, version = users.version + 1 " +
+		"RETURNING version"
+	var version int
+	if err := r.q.QueryRowContext(ctx, r.rebind(query), name, email).Scan(&version); err != nil {
+		return false, dberr.Translate(err)
+	}
+	return version == 1, nil
+}
+
+// Update 削除されていないユーザーの情報をバージョンが一致する場合のみ更新
+func (r *UserRepository) Update(ctx context.Context, id int, name, email string, version int) error {
+	query := "UPDATE users SET name = ?, email = ?, version = version + 1 " +
+		"WHERE id = ? AND version = ? AND deleted_at IS NULL"
+	result, err := r.q.ExecContext(ctx, r.rebind(query), name, email, id, version)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// Patch 指定したフィールドだけをバージョンが一致する場合のみ更新
+func (r *UserRepository) Patch(ctx context.Context, id int, patch model.UserPatch, version int) error {
+	sets := []string{"version = version + 1"}
+	var args []any
+	if patch.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *patch.Name)
+	}
+	if patch.Email != nil {
+		sets = append(sets, "email = ?")
+		args = append(args, *patch.Email)
+	}
+
+	query := "UPDATE users SET " + strings.Join(sets, ", ") +
+		" WHERE id = ? AND version = ? AND deleted_at IS NULL"
+	args = append(args, id, version)
+	result, err := r.q.ExecContext(ctx, r.rebind(query), args...)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// Delete ユーザーをバージョンが一致する場合のみ論理削除
+func (r *UserRepository) Delete(ctx context.Context, id int, version int) error {
+	query := "UPDATE users SET deleted_at = CURRENT_TIMESTAMP " +
+		"WHERE id = ? AND version = ? AND deleted_at IS NULL"
+	result, err := r.q.ExecContext(ctx, r.rebind(query), id, version)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+	return r.checkVersionMatched(ctx, result, id)
+}
+
+// checkVersionMatched バージョン付きの更新で対象行がなかった場合にその原因をエラーとして返す
+// ユーザーが存在しなければErrNotFound、存在すればバージョン不一致としてErrVersionConflictを返す
+// 更新では必ずversionを増やすため、値が変わらない更新でもMySQLの影響行数は0にならない
+func (r *UserRepository) checkVersionMatched(ctx context.Context, result sql.Result, id int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return model.ErrVersionConflict
+}
+
+// Restore 論理削除したユーザーを復元
+func (r *UserRepository) Restore(ctx context.Context, id int) error {
+	query := "UPDATE users SET deleted_at = NULL, version = version + 1 WHERE id = ? AND deleted_at IS NOT NULL"
+	result, err := r.q.ExecContext(ctx, r.rebind(query), id)
+	if err != nil {
+		return dberr.Translate(err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return model.ErrNotFound
+	}
+	return nil
+}
+
+// Purge olderThanより前に論理削除したユーザーを物理削除し、削除件数を返す
+func (r *UserRepository) Purge(ctx context.Context, olderThan time.Time) (int64, error) {
+	query := "DELETE FROM users WHERE deleted_at < ?"
+	result, err := r.q.ExecContext(ctx, r.rebind(query), olderThan.UTC())
+	if err != nil {
+		return 0, dberr.Translate(err)
+	}
+	return result.RowsAffected()
+}
+
+// WithTx fnをトランザクション内で実行する
+// fnがエラーを返すかパニックした場合はロールバックし、それ以外はコミットする
+func (r *UserRepository) WithTx(ctx context.Context, fn func(repo model.UserRepository) error) error {
+	// 既にトランザクション内であれば同じトランザクションに参加する
+	if r.inTx() {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(&UserRepository{db: r.db, q: tx, driver: r.driver}); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+// inTx トランザクション内のリポジトリかどうか
+func (r *UserRepository) inTx() bool {
+	_, ok := r.q.(*sql.Tx)
+	return ok
 }
 
 // Close データベース接続を閉じる
+// トランザクション内のリポジトリは接続を所有しないため何もしない
 func (r *UserRepository) Close() error {
+	if r.inTx() {
+		return nil
+	}
 	return r.db.Close()
 }